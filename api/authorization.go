@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GlintPay/gccs/auth"
+)
+
+// Authorizer decides whether the caller may read what was requested, returning a context carrying anything further
+// they're allowed to do
+type Authorizer interface {
+	Authorize(ctxt context.Context, applications []string, profiles []string, labels string) (context.Context, error)
+}
+
+func (rtr *Routing) authorize(r *http.Request, req ConfigurationRequest) (*http.Request, error) {
+	if principal, ok := auth.FromRequest(r); ok {
+		if err := principal.MayRead(req.Applications); err != nil {
+			return r, err
+		}
+	}
+
+	if rtr.Authorizer == nil {
+		return r, nil
+	}
+
+	ctxt, err := rtr.Authorizer.Authorize(r.Context(), req.Applications, req.Profiles, req.Labels.Branch)
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(ctxt), nil
+}
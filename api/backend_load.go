@@ -3,17 +3,42 @@ package api
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
 	"github.com/GlintPay/gccs/backend"
 	gotel "github.com/GlintPay/gccs/otel"
 	"github.com/GlintPay/gccs/utils"
 	"github.com/rs/zerolog/log"
-	"sort"
-	"strings"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
+// maxParallelFiles bounds how many matched files are parsed at once, per backend
+var maxParallelFiles = runtime.GOMAXPROCS(0)
+
 func LoadConfigurations(ctxt context.Context, s backend.Backends, req ConfigurationRequest) (*Source, error) {
-	sorter := backend.Sorter{Backends: s}
-	sort.SliceStable(s, sorter.Sort())
+	source, states, err := loadStates(ctxt, s, req)
+	if err != nil {
+		return &Source{}, err
+	}
+
+	if e := addPropertySources(ctxt, req, states, source); e != nil {
+		return &Source{}, e
+	}
+	return source, nil
+}
+
+// loadStates gets the current state of every backend in parallel, without reading any files yet, so the versions
+// can be checked first. States are returned in backend order.
+func loadStates(ctxt context.Context, s backend.Backends, req ConfigurationRequest) (*Source, []*backend.State, error) {
+	ordered := slices.Clone(s)
+	sorter := backend.Sorter{Backends: ordered}
+	sort.SliceStable(ordered, sorter.Sort())
 
 	sourceName := ""
 	if len(req.Applications) > 0 { // TODO Validate higher up?
@@ -29,38 +54,117 @@ func LoadConfigurations(ctxt context.Context, s backend.Backends, req Configurat
 		PropertySources: make([]PropertySource, 0),
 	}
 
-	for _, each := range s {
-		if e := loadConfiguration(ctxt, each, req, source); e != nil {
-			return &Source{}, e
+	states := make([]*backend.State, len(ordered))
+
+	group, groupCtxt := errgroup.WithContext(ctxt)
+	for i, each := range ordered {
+		group.Go(func() error {
+			state, e := loadState(groupCtxt, each, req)
+			states[i] = state
+			return e
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, state := range states {
+		// Join new version to existing, FWIW
+		if len(state.Version) > 0 {
+			if len(source.Version) > 0 {
+				source.Version += "; "
+			}
+			source.Version += state.Version
+		}
+
+		if len(source.Label) == 0 {
+			source.Label = state.Label
+		}
+
+		if state.Stale {
+			source.Stale = true
 		}
 	}
-	return source, nil
+
+	return source, states, nil
 }
 
-func loadConfiguration(ctxt context.Context, s backend.Backend, req ConfigurationRequest, source *Source) error {
+func loadState(ctxt context.Context, s backend.Backend, req ConfigurationRequest) (*backend.State, error) {
 	// log.Debug().Msgf("Requesting: %s/%s/[%s]", req.Applications, req.Profiles, req.Labels)
 
 	if req.EnableTrace {
-		_, span := gotel.GetTracer(ctxt).Start(ctxt, "loadConfiguration", gotel.ServerOptions)
+		var span trace.Span
+		ctxt, span = gotel.GetTracer(ctxt).Start(ctxt, "loadConfiguration", gotel.ServerOptions,
+			trace.WithAttributes(attribute.String("backend", s.Name()), attribute.Int("order", s.Order())))
 		defer span.End()
+
+		state, err := s.GetCurrentState(ctxt, req.Applications, req.Profiles, req.Labels.Branch, req.RefreshBackend)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return state, err
 	}
 
-	state, err := s.GetCurrentState(ctxt, req.Labels.Branch, req.RefreshBackend)
-	if err != nil {
+	return s.GetCurrentState(ctxt, req.Applications, req.Profiles, req.Labels.Branch, req.RefreshBackend)
+}
+
+// addPropertySources parses the matching files of every backend in parallel, adding them in backend order, then
+// in the order each backend presents them
+func addPropertySources(ctxt context.Context, req ConfigurationRequest, states []*backend.State, source *Source) error {
+	loaded := make([][]PropertySource, len(states))
+
+	group, groupCtxt := errgroup.WithContext(ctxt)
+	for i, state := range states {
+		group.Go(func() error {
+			sources, e := loadPropertySources(groupCtxt, req, state)
+			loaded[i] = sources
+			return e
+		})
+	}
+	if err := group.Wait(); err != nil {
 		return err
 	}
 
-	// Join new version to existing, FWIW
-	if len(state.Version) > 0 {
-		if len(source.Version) > 0 {
-			source.Version += "; "
-		}
-		source.Version += state.Version
+	for _, each := range loaded {
+		source.PropertySources = append(source.PropertySources, each...)
+	}
+	return nil
+}
+
+func loadPropertySources(ctxt context.Context, req ConfigurationRequest, state *backend.State) ([]PropertySource, error) {
+	files, err := matchingFiles(req, state)
+	if err != nil {
+		return nil, err
 	}
 
-	////////////////////////////////////////////////////
+	sources := make([]PropertySource, len(files))
+
+	group, groupCtxt := errgroup.WithContext(ctxt)
+	group.SetLimit(maxParallelFiles)
+	for i, f := range files {
+		group.Go(func() error {
+			if e := groupCtxt.Err(); e != nil {
+				return e
+			}
+
+			ps, e := toPropertySource(req, f)
+			sources[i] = ps
+			return e
+		})
+	}
+	if e := group.Wait(); e != nil {
+		return nil, e
+	}
+	return sources, nil
+}
 
-	addHandler := newDiscoveryHandler(req, source)
+func matchingFiles(req ConfigurationRequest, state *backend.State) ([]backend.File, error) {
+	var files []backend.File
+	addHandler := func(f backend.File) error {
+		files = append(files, f)
+		return nil
+	}
 
 	/* https://docs.spring.io/spring-cloud-config/docs/current/reference/html/#_quick_start
 	The HTTP service has resources in the form:
@@ -73,7 +177,7 @@ func loadConfiguration(ctxt context.Context, s backend.Backend, req Configuratio
 
 	"label" is an optional git label (defaults to "master".)
 	*/
-	err = state.Files.ForEach(func(f backend.File) error {
+	err := state.Files.ForEach(func(f backend.File) error {
 		readable, suffix := f.IsReadable()
 		if !readable {
 			return nil
@@ -109,11 +213,7 @@ func loadConfiguration(ctxt context.Context, s backend.Backend, req Configuratio
 		return nil
 	})
 
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return files, err
 }
 
 func findAmongProfiles(f backend.File, filename string, profile string, wantedProfiles []string, handler discoveryHandler) error {
@@ -135,34 +235,29 @@ var joinerFunc = func(k []string) string {
 	return strings.Join(k, ".")
 }
 
-func newDiscoveryHandler(req ConfigurationRequest, source *Source) discoveryHandler {
-	return func(f backend.File) error {
-		log.Info().Msgf("Adding property source: Config resource '%s' via location '%s'", f.FullyQualifiedName(), f.Location())
-
-		mapStructuredData, err := f.ToMap()
-		if err != nil {
-			return err
-		}
+func toPropertySource(req ConfigurationRequest, f backend.File) (PropertySource, error) {
+	log.Info().Msgf("Adding property source: Config resource '%s' via location '%s'", f.FullyQualifiedName(), f.Location())
 
-		if req.FlattenHierarchies {
-			mapStructuredData = utils.Flatten(mapStructuredData, joinerFunc)
+	mapStructuredData, err := f.ToMap()
+	if err != nil {
+		return PropertySource{}, err
+	}
 
-			if req.FlattenedIndexedLists {
-				flattenedIndexedLists(mapStructuredData)
+	if req.FlattenHierarchies {
+		mapStructuredData = utils.Flatten(mapStructuredData, joinerFunc)
 
-				// Reflatten just in case
-				mapStructuredData = utils.Flatten(mapStructuredData, joinerFunc)
-			}
-		}
+		if req.FlattenedIndexedLists {
+			flattenedIndexedLists(mapStructuredData)
 
-		ps := PropertySource{
-			Name:   f.FullyQualifiedName(),
-			Source: mapStructuredData,
+			// Reflatten just in case
+			mapStructuredData = utils.Flatten(mapStructuredData, joinerFunc)
 		}
-
-		source.PropertySources = append(source.PropertySources, ps)
-		return nil
 	}
+
+	return PropertySource{
+		Name:   f.FullyQualifiedName(),
+		Source: mapStructuredData,
+	}, nil
 }
 
 func flattenedIndexedLists(data map[string]any) {
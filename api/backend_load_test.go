@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/GlintPay/gccs/backend"
 	"github.com/GlintPay/gccs/backend/file"
 	"github.com/GlintPay/gccs/backend/git"
@@ -9,9 +11,11 @@ import (
 	goGit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 //goland:noinspection GoUnhandledErrorResult
@@ -82,6 +86,7 @@ c: d
 	assert.Equal(t, &Source{
 		Name:     "accounts",
 		Profiles: []string{"base", "production"},
+		Label:    "master",
 		Version:  _getHash(repo),
 		PropertySources: []PropertySource{
 			{
@@ -200,6 +205,7 @@ c: d344
 	assert.Equal(t, &Source{
 		Name:     "accounts",
 		Profiles: []string{"base", "production"},
+		Label:    "master",
 		Version:  _getHash(repo),
 		PropertySources: []PropertySource{
 			{
@@ -239,6 +245,66 @@ c: d344
 	}, got)
 }
 
+//goland:noinspection GoUnhandledErrorResult
+func TestLoadConfigurationWithGitSearchPaths(t *testing.T) {
+
+	gitDir, err := os.MkdirTemp("", "*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(gitDir)
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	assert.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	_writeGitFile(t, gitDir, wt, "application.yaml", `a: root`)
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", `b: root`)
+	_writeGitFile(t, gitDir, wt, "accounts/accounts.yaml", `b: app-dir`)
+	_writeGitFile(t, gitDir, wt, "shared/payments/application.yaml", `a: shared`)
+	_writeGitFile(t, gitDir, wt, "config/production/application-production.yaml", `c: production`)
+	_writeGitFile(t, gitDir, wt, "security/security.yaml", `d: not-wanted`)
+	_writeGitFile(t, gitDir, wt, "accounts/nested/accounts.yaml", `e: too-deep`)
+
+	var backends backend.Backends
+	backends = append(backends, &git.Backend{
+		Config: config.GitConfig{
+			SearchPaths: []string{"{application}", "shared/*", "config/{profile}"},
+		},
+		Repo: repo,
+	})
+
+	req := ConfigurationRequest{
+		Applications:   []string{"accounts"},
+		Profiles:       []string{"production"},
+		RefreshBackend: false,
+	}
+
+	ctxt := context.Background()
+
+	got, err := LoadConfigurations(ctxt, backends, req)
+	assert.NoError(t, err)
+	assert.Equal(t, &Source{
+		Name:     "accounts",
+		Profiles: []string{"production"},
+		Label:    "master",
+		Version:  _getHash(repo),
+		PropertySources: []PropertySource{
+			{Name: "/accounts.yaml", Source: map[string]any{"b": "root"}},
+			{Name: "/application.yaml", Source: map[string]any{"a": "root"}},
+			{Name: "/config/production/application-production.yaml", Source: map[string]any{"c": "production"}},
+			{Name: "/shared/payments/application.yaml", Source: map[string]any{"a": "shared"}},
+			{Name: "/accounts/accounts.yaml", Source: map[string]any{"b": "app-dir"}},
+		},
+	}, got)
+
+	resolver := Resolver{}
+	values, metadata, err := resolver.ReconcileProperties(ctxt, req.Applications, req.Profiles, InjectedProperties{}, got)
+	assert.NoError(t, err)
+	assert.Equal(t, ResolvedConfigValues{"a": "shared", "b": "app-dir", "c": "production"}, values)
+	assert.Equal(t, "accounts.yaml > accounts.yaml > application-production.yaml > application.yaml > application.yaml", metadata.PrecedenceDisplayMessage)
+}
+
 func TestLoadConfigurationWantingApplications(t *testing.T) {
 
 	fileDir, err := os.MkdirTemp("", "*")
@@ -465,6 +531,7 @@ c: d344
 	assert.Equal(t, &Source{
 		Name:     "accounts",
 		Profiles: []string{"base", "production"},
+		Label:    "master",
 		Version:  _getHash(repo), // two sources joined
 		PropertySources: []PropertySource{
 			{
@@ -570,8 +637,153 @@ var sig = &object.Signature{
 	Email: "a@b.com",
 }
 
+func TestLoadConfigurationOrderIsDeterministic(t *testing.T) {
+	var slowFiles []backend.File
+	for i := range 20 {
+		// Earlier files take longer, so would finish last
+		slowFiles = append(slowFiles, delayedFile{name: fmt.Sprintf("accounts-p%02d.yaml", i), delay: time.Duration(20-i) * time.Millisecond})
+	}
+
+	backends := backend.Backends{
+		&delayedBackend{name: "second", order: 2, files: []backend.File{delayedFile{name: "application.yaml"}}},
+		&delayedBackend{name: "first", order: 1, delay: 20 * time.Millisecond, files: slowFiles},
+	}
+
+	var profiles []string
+	for i := range 20 {
+		profiles = append(profiles, fmt.Sprintf("p%02d", i))
+	}
+
+	got, err := LoadConfigurations(context.Background(), backends, ConfigurationRequest{Applications: []string{"accounts"}, Profiles: profiles})
+	require.NoError(t, err)
+
+	var names []string
+	for _, each := range got.PropertySources {
+		names = append(names, each.Name)
+	}
+
+	var want []string
+	for i := range 20 {
+		want = append(want, fmt.Sprintf("first/accounts-p%02d.yaml", i))
+	}
+	want = append(want, "second/application.yaml")
+
+	assert.Equal(t, want, names)
+	assert.Equal(t, "first-version; second-version", got.Version)
+
+	// The backends as given are left alone
+	assert.Equal(t, "second", backends[0].Name())
+}
+
+func TestLoadConfigurationFailsIfAnyBackendFails(t *testing.T) {
+	backends := backend.Backends{
+		&delayedBackend{name: "ok", order: 1, files: []backend.File{delayedFile{name: "application.yaml"}}},
+		&delayedBackend{name: "broken", order: 2, err: errors.New("unavailable")},
+	}
+
+	_, err := LoadConfigurations(context.Background(), backends, ConfigurationRequest{Applications: []string{"accounts"}})
+	assert.EqualError(t, err, "unavailable")
+
+	backends = backend.Backends{
+		&delayedBackend{name: "unparseable", order: 1, files: []backend.File{delayedFile{name: "application.yaml", err: errors.New("bad yaml")}}},
+	}
+
+	_, err = LoadConfigurations(context.Background(), backends, ConfigurationRequest{Applications: []string{"accounts"}})
+	assert.EqualError(t, err, "bad yaml")
+}
+
+type delayedBackend struct {
+	name  string
+	order int
+	delay time.Duration
+	files []backend.File
+	err   error
+}
+
+func (d *delayedBackend) Order() int {
+	return d.order
+}
+
+func (d *delayedBackend) Name() string {
+	return d.name
+}
+
+func (d *delayedBackend) Init(context.Context, config.ApplicationConfiguration) error {
+	return nil
+}
+
+func (d *delayedBackend) GetCurrentState(context.Context, []string, []string, string, bool) (*backend.State, error) {
+	time.Sleep(d.delay)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	files := make([]backend.File, len(d.files))
+	for i, f := range d.files {
+		each := f.(delayedFile)
+		each.location = d.name
+		files[i] = each
+	}
+	return &backend.State{Version: d.name + "-version", Files: delayedFiles(files)}, nil
+}
+
+func (d *delayedBackend) Health(context.Context) (backend.Health, error) {
+	return backend.Health{}, nil
+}
+
+func (d *delayedBackend) Close() {}
+
+type delayedFiles []backend.File
+
+func (d delayedFiles) ForEach(f func(f backend.File) error) error {
+	for _, each := range d {
+		if e := f(each); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+type delayedFile struct {
+	name     string
+	location string
+	delay    time.Duration
+	err      error
+}
+
+func (d delayedFile) Name() string {
+	return d.name
+}
+
+func (d delayedFile) FullyQualifiedName() string {
+	return d.location + "/" + d.name
+}
+
+func (d delayedFile) Location() string {
+	return d.location
+}
+
+func (d delayedFile) IsReadable() (bool, string) {
+	return true, filepath.Ext(d.name)
+}
+
+func (d delayedFile) Data() backend.Blob {
+	return nil
+}
+
+func (d delayedFile) ToMap() (map[string]any, error) {
+	time.Sleep(d.delay)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return map[string]any{"name": d.name}, nil
+}
+
 func _writeGitFile(t *testing.T, gitDir string, wt *goGit.Worktree, filename string, contents string) {
-	err := os.WriteFile(filepath.Join(gitDir, filename), []byte(contents), 0644)
+	err := os.MkdirAll(filepath.Dir(filepath.Join(gitDir, filename)), 0755)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(gitDir, filename), []byte(contents), 0644)
 	assert.NoError(t, err)
 
 	_, err = wt.Add(filename)
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/backend/git"
+	"github.com/GlintPay/gccs/config"
+	"github.com/go-chi/chi/v5"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const benchmarkApplications = 100 // each with 3 files
+
+func BenchmarkResolveUncached(b *testing.B) {
+	benchmarkResolve(b, config.Cache{Disabled: true}, false)
+}
+
+func BenchmarkResolveCachedFiles(b *testing.B) {
+	benchmarkResolve(b, config.Cache{}, false)
+}
+
+func BenchmarkResolveCached(b *testing.B) {
+	benchmarkResolve(b, config.Cache{}, true)
+}
+
+// Requests cycle through every application, each resolved with 2 profiles
+func benchmarkResolve(b *testing.B, cacheConfig config.Cache, cacheOutputs bool) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	gitDir := b.TempDir()
+	_writeBenchmarkRepo(b, gitDir)
+
+	appConfig := config.ApplicationConfiguration{
+		Git:   config.GitConfig{Uri: gitDir, Basedir: filepath.Join(b.TempDir(), "clone"), CloneOnStart: true},
+		File:  config.FileConfig{Disabled: true},
+		Cache: cacheConfig,
+	}
+
+	gitBackend := &git.Backend{}
+	require.NoError(b, gitBackend.Init(context.Background(), appConfig))
+
+	router := chi.NewRouter()
+	routing := Routing{Backends: backend.Backends{gitBackend}, AppConfig: appConfig}
+	router.Route("/", func(r chi.Router) {
+		require.NoError(b, routing.SetupFunctionalRoutes(r))
+	})
+
+	if !cacheOutputs {
+		routing.outputs = nil
+	}
+
+	i := 0
+	for b.Loop() {
+		url := fmt.Sprintf("/app%03d/production,eu?resolve=true&norefresh", i%benchmarkApplications)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("%s returned %d: %s", url, rr.Code, rr.Body.String())
+		}
+		i++
+	}
+}
+
+func _writeBenchmarkRepo(b *testing.B, dir string) {
+	repo, err := goGit.PlainInit(dir, false)
+	require.NoError(b, err)
+
+	wt, err := repo.Worktree()
+	require.NoError(b, err)
+
+	write := func(name string, prefix string) {
+		var sb strings.Builder
+		sb.WriteString("shared:\n  url: https://${" + prefix + ".host}/api\n")
+		for k := 0; k < 40; k++ {
+			fmt.Fprintf(&sb, "%s:\n  key%02d: value-%02d\n  nested:\n    list: [a, b, c]\n    timeout: %d\n", prefix, k, k, k)
+		}
+		fmt.Fprintf(&sb, "%s.host: %s.internal\n", prefix, prefix)
+		require.NoError(b, os.WriteFile(filepath.Join(dir, name), []byte(sb.String()), 0644))
+	}
+
+	write("application.yaml", "defaults")
+	write("application-production.yaml", "production")
+	write("application-eu.yaml", "eu")
+
+	for a := 0; a < benchmarkApplications; a++ {
+		app := fmt.Sprintf("app%03d", a)
+		write(app+".yaml", app)
+		write(app+"-production.yaml", app+"-production")
+		write(app+"-eu.yaml", app+"-eu")
+	}
+
+	require.NoError(b, wt.AddGlob("*.yaml"))
+	_, err = wt.Commit("", &goGit.CommitOptions{Author: sig})
+	require.NoError(b, err)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// entityTag is a strong validator for the exact response body: the backend version(s) and the options that shape
+// the output are included alongside the content itself, so e.g. pretty-printed and compact output never share a tag
+func entityTag(req ConfigurationRequest, resolved bool, version string, body []byte) string {
+	digest := sha256.New()
+	for _, each := range []string{
+		version,
+		strconv.FormatBool(resolved),
+		strconv.FormatBool(req.FlattenHierarchies),
+		strconv.FormatBool(req.FlattenedIndexedLists),
+		strconv.FormatBool(req.PrettyPrintJson),
+	} {
+		digest.Write([]byte(each))
+		digest.Write([]byte{0})
+	}
+	digest.Write(body)
+
+	return `"` + hex.EncodeToString(digest.Sum(nil)[:16]) + `"`
+}
+
+// writeNotModified sets the ETag, and if the client already has that version sends `304 Not Modified` with no body
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// If-None-Match uses weak comparison, so any `W/` prefix is ignored
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, each := range strings.Split(ifNoneMatch, ",") {
+		candidate := strings.TrimPrefix(strings.TrimSpace(each), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityTag(t *testing.T) {
+	req := ConfigurationRequest{}
+	body := []byte(`{"a":"b"}`)
+
+	etag := entityTag(req, true, "abc", body)
+	assert.Equal(t, etag, entityTag(req, true, "abc", body))
+
+	assert.NotEqual(t, etag, entityTag(req, true, "abc", []byte(`{"a":"c"}`)))
+	assert.NotEqual(t, etag, entityTag(req, true, "abd", body))
+	assert.NotEqual(t, etag, entityTag(req, false, "abc", body))
+	assert.NotEqual(t, etag, entityTag(ConfigurationRequest{PrettyPrintJson: true}, true, "abc", body))
+	assert.NotEqual(t, etag, entityTag(ConfigurationRequest{FlattenHierarchies: true}, true, "abc", body))
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{ifNoneMatch: "", want: false},
+		{ifNoneMatch: `"abc"`, want: true},
+		{ifNoneMatch: `W/"abc"`, want: true},
+		{ifNoneMatch: `"xyz", "abc"`, want: true},
+		{ifNoneMatch: `*`, want: true},
+		{ifNoneMatch: `"xyz"`, want: false},
+		{ifNoneMatch: `abc`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ifNoneMatch, func(t *testing.T) {
+			assert.Equal(t, tt.want, etagMatches(tt.ifNoneMatch, `"abc"`))
+		})
+	}
+}
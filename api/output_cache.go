@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GlintPay/gccs/auth"
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/cache"
+	"github.com/GlintPay/gccs/config"
+)
+
+// output is a serialised response, as cached
+type output struct {
+	body     []byte
+	etag     string
+	metadata ResolutionMetadata
+}
+
+func newOutputCache(cfg config.Cache) *cache.LRU[string, output] {
+	cfg = cfg.Validate()
+	if cfg.Disabled {
+		return nil
+	}
+	return cache.New[string, output]("outputs", cfg.MaxOutputs)
+}
+
+// loadOutput reads, resolves and serialises the configuration, unless an identical request was already served for
+// the same backend versions
+func (rtr *Routing) loadOutput(ctxt context.Context, req ConfigurationRequest, resolve bool, source *Source, states []*backend.State) (output, error) {
+	key, cacheable := outputKey(req, resolve, auth.MayResolveK8sSecrets(ctxt), source, states)
+	cacheable = cacheable && rtr.outputs != nil
+
+	if cacheable {
+		if found, ok := rtr.outputs.Get(key); ok {
+			return found, nil
+		}
+	}
+
+	if e := addPropertySources(ctxt, req, states, source); e != nil {
+		return output{}, e
+	}
+
+	var result output
+	var err error
+
+	if resolve {
+		resolver := rtr.newResolver(req)
+		values, metadata, e := resolver.ReconcileProperties(ctxt, req.Applications, req.Profiles, InjectedProperties{}, source)
+		if e != nil {
+			return output{}, e
+		}
+
+		result.metadata = metadata
+		result.body, err = marshalResponseJSON(values, req.PrettyPrintJson)
+	} else {
+		result.body, err = marshalResponseJSON(source, req.PrettyPrintJson)
+	}
+
+	if err != nil {
+		return output{}, err
+	}
+
+	result.etag = entityTag(req, resolve, source.Version, result.body)
+
+	if cacheable {
+		var ttl time.Duration
+		if result.metadata.UsesK8s {
+			ttl = time.Duration(rtr.AppConfig.Cache.Validate().K8sTtlMillis) * time.Millisecond
+		}
+		rtr.outputs.Put(key, result, ttl)
+	}
+	return result, nil
+}
+
+// outputKey identifies the response to a request, which can only be cached if every backend reports a version. Since
+// resolving K8s secrets can be forbidden, that permission is part of the key.
+func outputKey(req ConfigurationRequest, resolve bool, k8sSecrets bool, source *Source, states []*backend.State) (string, bool) {
+	for _, each := range states {
+		if each.Version == "" {
+			return "", false
+		}
+	}
+
+	return strings.Join([]string{
+		source.Version,
+		source.Label,
+		req.Labels.Branch,
+		strings.Join(req.Applications, ","),
+		strings.Join(req.Profiles, ","),
+		strconv.FormatBool(resolve),
+		strconv.FormatBool(k8sSecrets),
+		strconv.FormatBool(req.FlattenHierarchies),
+		strconv.FormatBool(req.FlattenedIndexedLists),
+		strconv.FormatBool(req.PrettyPrintJson),
+	}, "\x00"), true
+}
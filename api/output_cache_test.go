@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/backend/git"
+	"github.com/GlintPay/gccs/config"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		cache     config.Cache
+		usesK8s   bool
+		wait      time.Duration
+		wantCalls int32
+	}{
+		{name: "cached", wantCalls: 1},
+		{name: "disabled", cache: config.Cache{Disabled: true}, wantCalls: 2},
+		{name: "k8s within ttl", cache: config.Cache{K8sTtlMillis: 60000}, usesK8s: true, wantCalls: 1},
+		{name: "k8s expired", cache: config.Cache{K8sTtlMillis: 1}, usesK8s: true, wait: 5 * time.Millisecond, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+
+			repo, err := goGit.PlainInit(gitDir, false)
+			require.NoError(t, err)
+
+			wt, err := repo.Worktree()
+			require.NoError(t, err)
+
+			_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: b")
+
+			router, routing := setUpRouter(t, backend.Backends{&git.Backend{Repo: repo}}, false)
+			routing.AppConfig.Cache = tt.cache
+			routing.outputs = newOutputCache(tt.cache)
+
+			resolver := &countingResolver{usesK8s: tt.usesK8s}
+			routing.resolverGetter = func() Resolvable { return resolver }
+
+			get := func() *httptest.ResponseRecorder {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/production?resolve=true&norefresh", nil))
+				require.Equal(t, http.StatusOK, rr.Code)
+				return rr
+			}
+
+			first := get()
+			time.Sleep(tt.wait)
+			second := get()
+
+			assert.Equal(t, tt.wantCalls, resolver.calls.Load())
+			assert.Equal(t, first.Body.String(), second.Body.String())
+			assert.Equal(t, first.Header(), second.Header())
+
+			// A new commit is a new version
+			_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: c")
+			get()
+
+			assert.Equal(t, tt.wantCalls+1, resolver.calls.Load())
+		})
+	}
+}
+
+func TestOutputKey(t *testing.T) {
+	req := ConfigurationRequest{Applications: []string{"accounts"}, Profiles: []string{"production"}}
+	source := &Source{Version: "abc", Label: "main"}
+	states := []*backend.State{{Version: "abc"}}
+
+	key, cacheable := outputKey(req, true, true, source, states)
+	assert.True(t, cacheable)
+
+	other, _ := outputKey(req, false, true, source, states)
+	assert.NotEqual(t, key, other)
+
+	other, _ = outputKey(ConfigurationRequest{Applications: []string{"accounts"}, Profiles: []string{"production", "eu"}}, true, true, source, states)
+	assert.NotEqual(t, key, other)
+
+	other, _ = outputKey(req, true, true, &Source{Version: "abc", Label: "feature"}, states)
+	assert.NotEqual(t, key, other)
+
+	other, _ = outputKey(req, true, false, source, states)
+	assert.NotEqual(t, key, other)
+
+	// e.g. the file backend
+	_, cacheable = outputKey(req, true, true, source, []*backend.State{{Version: "abc"}, {}})
+	assert.False(t, cacheable)
+}
+
+type countingResolver struct {
+	calls   atomic.Int32
+	usesK8s bool
+}
+
+func (c *countingResolver) ReconcileProperties(_ context.Context, _ []string, _ []string, _ InjectedProperties, rawSource *Source) (ResolvedConfigValues, ResolutionMetadata, error) {
+	c.calls.Add(1)
+	return ResolvedConfigValues{"sources": len(rawSource.PropertySources)}, ResolutionMetadata{UsesK8s: c.usesK8s}, nil
+}
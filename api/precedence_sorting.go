@@ -15,14 +15,15 @@ func (ps Sorter) Sort() func(i, j int) bool {
 	return func(i, j int) bool {
 		left := ps.Sources[i]
 		adjustedLeftName := utils.StripGitPrefix(left.Name)
-		// application.* is always bottom of the heap
-		if strings.HasPrefix(adjustedLeftName, utils.BaseLevel) {
-			return true
-		}
 
 		right := ps.Sources[j]
 		adjustedRightName := utils.StripGitPrefix(right.Name)
 
+		// application.* is always bottom of the heap. Same-named files from different directories keep their discovery order
+		if strings.HasPrefix(adjustedLeftName, utils.BaseLevel) {
+			return !strings.HasPrefix(adjustedRightName, utils.BaseLevel)
+		}
+
 		if strings.HasPrefix(adjustedRightName, utils.BaseLevel) {
 			return false
 		}
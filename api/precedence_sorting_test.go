@@ -46,3 +46,26 @@ func TestBasic(t *testing.T) {
 
 	assert.Equal(t, expected, sources)
 }
+
+func TestSameNamesKeepDiscoveryOrder(t *testing.T) {
+	expected := []PropertySource{
+		{Name: "repo/application.yml", Source: EmptySource},
+		{Name: "repo/shared/application.yml", Source: EmptySource},
+		{Name: "repo/application-test.yml", Source: EmptySource},
+		{Name: "repo/other-service.yml", Source: EmptySource},
+		{Name: "repo/other-service/other-service.yml", Source: EmptySource},
+	}
+
+	sources := []PropertySource{
+		{Name: "repo/other-service.yml", Source: EmptySource},
+		{Name: "repo/application.yml", Source: EmptySource},
+		{Name: "repo/application-test.yml", Source: EmptySource},
+		{Name: "repo/shared/application.yml", Source: EmptySource},
+		{Name: "repo/other-service/other-service.yml", Source: EmptySource},
+	}
+
+	sorter := Sorter{AppNames: []string{"other-service"}, Profiles: []string{"test"}, Sources: sources}
+	sort.SliceStable(sources, sorter.Sort())
+
+	assert.Equal(t, expected, sources)
+}
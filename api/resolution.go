@@ -26,6 +26,16 @@ type Resolver struct {
 	propertiesResolverGetter func(context.Context, ResolvedConfigValues) PropertiesResolvable
 }
 
+// NewResolver for requests like `req`, resolving `${k8s/...}` placeholders via `k8sResolver`, if not nil
+func NewResolver(req ConfigurationRequest, templateConfig config.GoTemplate, k8sResolver *k8s.Resolver) *Resolver {
+	return &Resolver{
+		flattenedStructure: req.FlattenedIndexedLists,
+		templateConfig:     templateConfig,
+		enableTrace:        req.EnableTrace,
+		k8sResolver:        k8sResolver,
+	}
+}
+
 func (f *Resolver) ReconcileProperties(ctxt context.Context, applicationNames []string, profileNames []string, injections InjectedProperties, rawSource *Source) (ResolvedConfigValues, ResolutionMetadata, error) {
 	if f.enableTrace {
 		_, span := gotel.GetTracer(ctxt).Start(ctxt, "reconcile", gotel.ServerOptions)
@@ -42,28 +52,10 @@ func (f *Resolver) ReconcileProperties(ctxt context.Context, applicationNames []
 		}
 	}
 
-	// Deterministic sorting, regardless of any other implicit ordering
-	sorter := Sorter{AppNames: applicationNames, Profiles: profileNames, Sources: rawSource.PropertySources}
-	sort.SliceStable(rawSource.PropertySources, sorter.Sort())
+	f.merge(reconciled, applicationNames, profileNames, rawSource)
 
 	sourceNames := getPropertySourceNames(rawSource.PropertySources)
 
-	var listsToRemove []map[string]any
-	if f.flattenedStructure {
-		listsToRemove = findCompletelyReplacedFlattenedLists(rawSource.PropertySources)
-	}
-
-	for i, ps := range rawSource.PropertySources {
-		for k, v := range ps.Source {
-
-			if f.flattenedStructure && shouldSkipCompletelyReplacedFlattenedList(ps.Name, listsToRemove[i], k) {
-				continue
-			}
-
-			f.overrideValue(reconciled, k, v, ps.Name)
-		}
-	}
-
 	// Handle embedded references: ${propertyName} and ${propertyName:defaultValueIfMissing}. NB. Blank values don't trigger default.
 	rr := f.newPropertiesResolverGetter(ctxt, applicationNames, profileNames, reconciled)
 	if _, e := rr.resolvePlaceholdersFromTop(); e != nil {
@@ -83,9 +75,38 @@ func (f *Resolver) ReconcileProperties(ctxt context.Context, applicationNames []
 
 	return reconciled, ResolutionMetadata{
 		PrecedenceDisplayMessage: sourceNames,
+		UsesK8s:                  usesK8s(rr),
 	}, nil
 }
 
+// merge the property sources into `reconciled`, in order of precedence, which they're left sorted by
+func (f *Resolver) merge(reconciled ResolvedConfigValues, applicationNames []string, profileNames []string, rawSource *Source) {
+	// Deterministic sorting, regardless of any other implicit ordering
+	sorter := Sorter{AppNames: applicationNames, Profiles: profileNames, Sources: rawSource.PropertySources}
+	sort.SliceStable(rawSource.PropertySources, sorter.Sort())
+
+	var listsToRemove []map[string]any
+	if f.flattenedStructure {
+		listsToRemove = findCompletelyReplacedFlattenedLists(rawSource.PropertySources)
+	}
+
+	for i, ps := range rawSource.PropertySources {
+		for k, v := range ps.Source {
+
+			if f.flattenedStructure && shouldSkipCompletelyReplacedFlattenedList(ps.Name, listsToRemove[i], k) {
+				continue
+			}
+
+			f.overrideValue(reconciled, k, v, ps.Name)
+		}
+	}
+}
+
+func usesK8s(rr PropertiesResolvable) bool {
+	pr, ok := rr.(*PropertiesResolver)
+	return ok && pr.k8sLookups > 0
+}
+
 func (f *Resolver) overrideValue(reconciled map[string]any, k string, v any, source string) {
 	if reconciled[k] == nil {
 		reconciled[k] = v
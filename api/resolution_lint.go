@@ -0,0 +1,204 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GlintPay/gccs/resolver/k8s"
+)
+
+// Rules that Lint reports problems against
+const (
+	RuleUnresolvedPlaceholder   = "unresolved-placeholder"
+	RulePlaceholderCycle        = "placeholder-cycle"
+	RulePointlessOverride       = "pointless-override"
+	RuleUnknownTemplateFunction = "unknown-template-function"
+	RuleInvalidTemplate         = "invalid-template"
+)
+
+// Problem with a merged value, that would otherwise only show up, if at all, when it's requested
+type Problem struct {
+	Rule    string
+	Key     string
+	Source  string // name of the property source that set the key
+	Message string
+}
+
+var unknownFunctionRegex = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// Lint merges the property sources as ReconcileProperties would, then checks the templates and placeholders of every
+// value without resolving them. `${k8s/...}` placeholders aren't checked.
+func (f *Resolver) Lint(applicationNames []string, profileNames []string, rawSource *Source) []Problem {
+	merged := make(ResolvedConfigValues)
+	f.merge(merged, applicationNames, profileNames, rawSource)
+
+	// Sources are now in order of precedence, so the last to set each key is the one that counts
+	origins := map[string]string{}
+	for _, ps := range rawSource.PropertySources {
+		for k := range ps.Source {
+			origins[k] = ps.Name
+		}
+	}
+
+	var problems []Problem
+	for _, each := range f.pointlessOverrides {
+		problems = append(problems, Problem{
+			Rule:    RulePointlessOverride,
+			Key:     each.key,
+			Source:  each.source,
+			Message: fmt.Sprintf("[%s] is already %v, so needn't be set again", each.key, each.value),
+		})
+	}
+
+	templateConfig := f.templateConfig.Validate()
+	templatesData := map[string]any{
+		"Applications": applicationNames,
+		"Profiles":     profileNames,
+	}
+
+	references := map[string][]string{}
+	for _, key := range sortedValueKeys(merged) {
+		for _, value := range stringValues(merged[key]) {
+			expanded, err := executeTemplate(value, templateConfig, templatesData)
+			if err != nil {
+				problems = append(problems, templateProblem(key, origins[key], err))
+				continue
+			}
+
+			for _, match := range placeholderRegex.FindAllString(expanded, -1) {
+				content := strings.TrimSpace(match[2 : len(match)-1])
+				if k8s.IsK8sPlaceholder(content) {
+					continue
+				}
+
+				clause := strings.Split(content, ":")
+				name := clause[0]
+
+				switch _, found := merged[name]; {
+				case name == "":
+					problems = append(problems, Problem{Rule: RuleUnresolvedPlaceholder, Key: key, Source: origins[key], Message: fmt.Sprintf("[%s] has an empty placeholder, %s", key, match)})
+				case found:
+					// Only strings are resolved in turn, so can lead back here
+					if _, ok := merged[name].(string); ok {
+						references[key] = append(references[key], name)
+					}
+				case len(clause) < 2:
+					problems = append(problems, Problem{Rule: RuleUnresolvedPlaceholder, Key: key, Source: origins[key], Message: fmt.Sprintf("[%s] refers to ${%s}, which has no value or default", key, name)})
+				}
+			}
+		}
+	}
+
+	for _, cycle := range findCycles(references) {
+		problems = append(problems, Problem{
+			Rule:    RulePlaceholderCycle,
+			Key:     cycle[0],
+			Source:  origins[cycle[0]],
+			Message: fmt.Sprintf("placeholders refer back to themselves: %s", strings.Join(cycle, " -> ")),
+		})
+	}
+
+	return problems
+}
+
+func templateProblem(key string, source string, err error) Problem {
+	if match := unknownFunctionRegex.FindStringSubmatch(err.Error()); match != nil {
+		return Problem{Rule: RuleUnknownTemplateFunction, Key: key, Source: source, Message: fmt.Sprintf("[%s] calls unknown template function %s", key, match[1])}
+	}
+	return Problem{Rule: RuleInvalidTemplate, Key: key, Source: source, Message: fmt.Sprintf("[%s] has an invalid template: %v", key, err)}
+}
+
+// The strings that would be resolved, as for resolvePlaceholders
+func stringValues(v any) []string {
+	switch typed := v.(type) {
+	case string:
+		return []string{typed}
+	case []any:
+		var values []string
+		for _, each := range typed {
+			switch element := each.(type) {
+			case map[string]any:
+			case string:
+				values = append(values, element)
+			default:
+				values = append(values, fmt.Sprintf("%v", element))
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Each cycle once, starting from its first key alphabetically, and ending there too, e.g. [a b a]
+func findCycles(references map[string][]string) [][]string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	seen := map[string]bool{}
+
+	var cycles [][]string
+	var path []string
+
+	var visit func(key string)
+	visit = func(key string) {
+		state[key] = visiting
+		path = append(path, key)
+
+		for _, next := range references[key] {
+			switch state[next] {
+			case visiting:
+				start := 0
+				for path[start] != next {
+					start++
+				}
+				cycle := rotateToFirst(path[start:])
+				if id := strings.Join(cycle, " "); !seen[id] {
+					seen[id] = true
+					cycles = append(cycles, append(cycle, cycle[0]))
+				}
+			case 0:
+				visit(next)
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[key] = visited
+	}
+
+	keys := make([]string, 0, len(references))
+	for each := range references {
+		keys = append(keys, each)
+	}
+	sort.Strings(keys)
+
+	for _, each := range keys {
+		if state[each] == 0 {
+			visit(each)
+		}
+	}
+	return cycles
+}
+
+func rotateToFirst(cycle []string) []string {
+	first := 0
+	for i, each := range cycle {
+		if each < cycle[first] {
+			first = i
+		}
+	}
+	return append(append([]string{}, cycle[first:]...), cycle[:first]...)
+}
+
+func sortedValueKeys(values ResolvedConfigValues) []string {
+	keys := make([]string, 0, len(values))
+	for each := range values {
+		keys = append(keys, each)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lint(t *testing.T) {
+	tests := []struct {
+		name     string
+		sources  []PropertySource
+		template config.GoTemplate
+		expected []Problem
+	}{
+		{
+			name: "fine",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"host": "a.com", "port": 80}},
+				{Name: "/myapp.yml", Source: map[string]any{"url": "https://${host}:${port}${path:/}", "secret": "${k8s/secret:ns/name/key}", "hosts": []any{"${host}", 1}}},
+			},
+		},
+		{
+			name: "unresolved",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"url": "https://${host}", "empty": "${ }"}},
+				{Name: "/myapp.yml", Source: map[string]any{"hosts": []any{"${other}"}}},
+			},
+			expected: []Problem{
+				{Rule: RuleUnresolvedPlaceholder, Key: "empty", Source: "/application.yml", Message: "[empty] has an empty placeholder, ${ }"},
+				{Rule: RuleUnresolvedPlaceholder, Key: "hosts", Source: "/myapp.yml", Message: "[hosts] refers to ${other}, which has no value or default"},
+				{Rule: RuleUnresolvedPlaceholder, Key: "url", Source: "/application.yml", Message: "[url] refers to ${host}, which has no value or default"},
+			},
+		},
+		{
+			name: "cycles",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"c": "${b}", "b": "${a}", "a": "x${c}", "d": "${a}", "self": "${self}"}},
+			},
+			expected: []Problem{
+				{Rule: RulePlaceholderCycle, Key: "a", Source: "/application.yml", Message: "placeholders refer back to themselves: a -> c -> b -> a"},
+				{Rule: RulePlaceholderCycle, Key: "self", Source: "/application.yml", Message: "placeholders refer back to themselves: self -> self"},
+			},
+		},
+		{
+			name: "pointless override",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"owner": "Mine"}},
+				{Name: "/myapp.yml", Source: map[string]any{"owner": "Mine"}},
+			},
+			expected: []Problem{
+				{Rule: RulePointlessOverride, Key: "owner", Source: "/myapp.yml", Message: "[owner] is already Mine, so needn't be set again"},
+			},
+		},
+		{
+			name: "templates",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"name": "{{ index .Applications 0 | upper }}", "x": "{{ shout .Profiles }}", "y": "{{ end }}", "z": "{{ index .Profiles 3 }}"}},
+			},
+			expected: []Problem{
+				{Rule: RuleUnknownTemplateFunction, Key: "x", Source: "/application.yml", Message: "[x] calls unknown template function shout"},
+				{Rule: RuleInvalidTemplate, Key: "y", Source: "/application.yml", Message: "[y] has an invalid template: template: :1: unexpected {{end}}"},
+				{Rule: RuleInvalidTemplate, Key: "z", Source: "/application.yml", Message: "[z] has an invalid template: template: :1:3: executing \"\" at <index .Profiles 3>: error calling index: index out of range: 3"},
+			},
+		},
+		{
+			name: "placeholders from templates",
+			sources: []PropertySource{
+				{Name: "/application.yml", Source: map[string]any{"url": "<< printf \"${%s}\" \"host\" >>"}},
+			},
+			template: config.GoTemplate{LeftDelim: "<<", RightDelim: ">>"},
+			expected: []Problem{
+				{Rule: RuleUnresolvedPlaceholder, Key: "url", Source: "/application.yml", Message: "[url] refers to ${host}, which has no value or default"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewResolver(ConfigurationRequest{}, tt.template, nil)
+			problems := resolver.Lint([]string{"myapp"}, []string{"default"}, &Source{PropertySources: tt.sources})
+			assert.Equal(t, tt.expected, problems)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/GlintPay/gccs/auth"
 	"github.com/GlintPay/gccs/config"
 	"github.com/GlintPay/gccs/resolver/k8s"
 	"github.com/Masterminds/sprig"
@@ -28,6 +29,7 @@ type PropertiesResolver struct {
 	templateConfig config.GoTemplate
 	templatesData  map[string]any
 	k8sResolver    *k8s.Resolver
+	k8sLookups     int
 }
 
 var placeholderRegex = regexp.MustCompile(`\${([^}]*)}`)
@@ -72,25 +74,30 @@ var customFuncs = template.FuncMap{
 	},
 }
 
-// TODO Should missing properties be a configurable fatal error?
-func (pr *PropertiesResolver) resolveString(currentMap map[string]any, propertyName string, value string, stack map[string]any) string {
-	goTemplatesResult := value
-
-	// Look for possible Go templates
-	if strings.Contains(value, pr.templateConfig.LeftDelim) && strings.Contains(value, pr.templateConfig.RightDelim) {
-		var buf strings.Builder
-		tmpl, e := template.New("").Funcs(sprigFuncs).Funcs(customFuncs).Delims(pr.templateConfig.LeftDelim, pr.templateConfig.RightDelim).Parse(value)
-		if e != nil {
-			pr.error = e
-			return ""
-		}
+// Executes any Go templates in the value, else returns it as it is
+func executeTemplate(value string, templateConfig config.GoTemplate, data map[string]any) (string, error) {
+	if !strings.Contains(value, templateConfig.LeftDelim) || !strings.Contains(value, templateConfig.RightDelim) {
+		return value, nil
+	}
 
-		if e := tmpl.Execute(&buf, pr.templatesData); e != nil {
-			pr.error = e
-			return ""
-		}
+	tmpl, err := template.New("").Funcs(sprigFuncs).Funcs(customFuncs).Delims(templateConfig.LeftDelim, templateConfig.RightDelim).Parse(value)
+	if err != nil {
+		return "", err
+	}
 
-		goTemplatesResult = buf.String()
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// TODO Should missing properties be a configurable fatal error?
+func (pr *PropertiesResolver) resolveString(currentMap map[string]any, propertyName string, value string, stack map[string]any) string {
+	goTemplatesResult, e := executeTemplate(value, pr.templateConfig, pr.templatesData)
+	if e != nil {
+		pr.error = e
+		return ""
 	}
 
 	if pr.error != nil {
@@ -177,6 +184,14 @@ func (pr *PropertiesResolver) resolvePropertyName(name string) (any, bool) {
 // resolveK8sPlaceholder handles resolution of K8s secret/configmap placeholders.
 func (pr *PropertiesResolver) resolveK8sPlaceholder(placeholderContent string) string {
 	k8sPlaceholder, defaultValue := pr.parseK8sPlaceholderWithDefault(placeholderContent)
+	pr.k8sLookups++
+
+	if strings.HasPrefix(k8sPlaceholder, k8s.PrefixK8sSecret) && !auth.MayResolveK8sSecrets(pr.ctx) {
+		principal, _ := auth.FromContext(pr.ctx)
+		pr.error = &auth.DeniedError{Reason: fmt.Sprintf("[%s] may not resolve K8s secret [%s]", principal.Name, k8sPlaceholder)}
+		return UnresolvedPropertyResult
+	}
+
 	val, ok, err := pr.k8sResolver.Resolve(pr.ctx, k8sPlaceholder)
 	if err != nil {
 		pr.error = err
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/GlintPay/gccs/auth"
 	"github.com/GlintPay/gccs/config"
+	"github.com/GlintPay/gccs/resolver/k8s"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -421,3 +427,26 @@ func Test_parseK8sPlaceholderWithDefault(t *testing.T) {
 		})
 	}
 }
+
+func Test_resolveK8sSecretDenied(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.yml")
+	require.NoError(t, os.WriteFile(file, []byte("policies:\n  - name: readers\n    principals: [reader]\n"), 0600))
+
+	policies, err := auth.LoadPolicies(file, "master")
+	require.NoError(t, err)
+
+	ctxt, err := policies.Authorize(auth.WithPrincipal(context.Background(), auth.Principal{Name: "reader"}), []string{"accounts"}, nil, "")
+	require.NoError(t, err)
+
+	pr := PropertiesResolver{
+		ctx:         ctxt,
+		data:        map[string]any{"key": "${k8s/secret:backend/hubspot-api/api-key}"},
+		k8sResolver: k8s.NewResolver(nil, config.K8sConfig{}),
+	}
+
+	_, err = pr.resolvePlaceholdersFromTop()
+
+	var denied *auth.DeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "[reader] may not resolve K8s secret [k8s/secret:backend/hubspot-api/api-key]", denied.Reason)
+}
@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/GlintPay/gccs/auth"
 	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/cache"
 	"github.com/GlintPay/gccs/config"
 	"github.com/GlintPay/gccs/resolver/k8s"
 	"github.com/GlintPay/gccs/utils"
@@ -28,8 +31,14 @@ type Routing struct {
 	AppConfig   config.ApplicationConfiguration
 	Backends    backend.Backends
 	K8sResolver *k8s.Resolver
+	Authorizer  Authorizer // if nil, any caller may read anything
 
 	resolverGetter func() Resolvable
+	outputs        *cache.LRU[string, output]
+
+	watches         *watchPollers
+	stopWatches     chan struct{}
+	stopWatchesOnce sync.Once
 }
 
 func (rtr *Routing) SetupFunctionalRoutes(r chi.Router) error {
@@ -37,7 +46,12 @@ func (rtr *Routing) SetupFunctionalRoutes(r chi.Router) error {
 		return e
 	}
 
+	rtr.outputs = newOutputCache(rtr.AppConfig.Cache)
+	rtr.stopWatches = make(chan struct{})
+	rtr.watches = newWatchPollers()
+
 	r.Get("/{application}/{profiles}", rtr.propertySourcesHandler())
+	r.Get("/{application}/{profiles}/watch", rtr.watchHandler())
 	r.Get("/{application}/{profiles}/{labels}", rtr.propertySourcesHandler())
 	r.Patch("/{application}/{profiles}", rtr.propertySourcesHandlerWithInjections())
 	r.Patch("/{application}/{profiles}/{labels}", rtr.propertySourcesHandlerWithInjections())
@@ -54,32 +68,37 @@ func (rtr *Routing) propertySourcesHandler() http.HandlerFunc {
 			return
 		}
 
-		source, err := LoadConfigurations(r.Context(), rtr.Backends, req)
+		r, err = rtr.authorize(r, req)
 		if err != nil {
 			rtr.writeError(w, err)
 			return
 		}
 
-		var configJSONBytes []byte
-		var outputErr error
+		source, states, err := loadStates(r.Context(), rtr.Backends, req)
+		if err != nil {
+			rtr.writeError(w, err)
+			return
+		}
+
+		writeStaleHeader(w.Header(), source)
 
 		resolveVal := overrideBooleanDefault(queries.Get("resolve"), rtr.AppConfig.Defaults.ResolvePropertySources)
-		if resolveVal {
-			resolver := rtr.newResolver(req)
-			values, metadata, e := resolver.ReconcileProperties(r.Context(), req.Applications, req.Profiles, InjectedProperties{}, source)
-			if e != nil {
-				rtr.writeError(w, e)
-				return
-			}
 
-			writeHeaders(w.Header(), req, metadata, source)
+		result, err := rtr.loadOutput(r.Context(), req, resolveVal, source, states)
+		if err != nil {
+			rtr.writeError(w, err)
+			return
+		}
 
-			configJSONBytes, outputErr = marshalResponseJSON(values, req.PrettyPrintJson)
-		} else {
-			configJSONBytes, outputErr = marshalResponseJSON(source, req.PrettyPrintJson)
+		if resolveVal {
+			writeHeaders(w.Header(), req, result.metadata, source)
 		}
 
-		rtr.handleOutput(w, outputErr, configJSONBytes, req.LogResponses)
+		if writeNotModified(w, r, result.etag) {
+			return
+		}
+
+		rtr.handleOutput(w, nil, result.body, req.LogResponses)
 	}
 }
 
@@ -92,12 +111,20 @@ func (rtr *Routing) propertySourcesHandlerWithInjections() http.HandlerFunc {
 			return
 		}
 
+		r, err = rtr.authorize(r, req)
+		if err != nil {
+			rtr.writeError(w, err)
+			return
+		}
+
 		source, err := LoadConfigurations(r.Context(), rtr.Backends, req)
 		if err != nil {
 			rtr.writeError(w, err)
 			return
 		}
 
+		writeStaleHeader(w.Header(), source)
+
 		var configJSONBytes []byte
 		var outputErr error
 
@@ -143,10 +170,16 @@ func writeHeaders(header http.Header, req ConfigurationRequest, metadata Resolut
 	header.Set("X-Resolution-PrecedenceDisplayMessage", metadata.PrecedenceDisplayMessage)
 	header.Set("X-Resolution-Name", strings.Join(req.Applications, ","))
 	header.Set("X-Resolution-Profiles", strings.Join(req.Profiles, ","))
-	header.Set("X-Resolution-Label", "")
+	header.Set("X-Resolution-Label", source.Label)
 	header.Set("X-Resolution-Version", source.Version)
 }
 
+func writeStaleHeader(header http.Header, source *Source) {
+	if source.Stale {
+		header.Set("X-Resolution-Stale", "true")
+	}
+}
+
 func (rtr *Routing) handleOutput(w http.ResponseWriter, err error, bytes []byte, logResponses bool) {
 	if err != nil {
 		rtr.writeError(w, err)
@@ -162,12 +195,17 @@ func (rtr *Routing) handleOutput(w http.ResponseWriter, err error, bytes []byte,
 }
 
 func (rtr *Routing) writeError(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
+	var denied *auth.DeniedError
+	if errors.As(err, &denied) {
+		w.WriteHeader(http.StatusForbidden)
+		log.Warn().Err(err).Msg("Forbidden")
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Error().Err(err).Stack().Msg("Response error")
+	}
 
 	info := map[string]any{"message": err.Error()}
 	_ = json.NewEncoder(w).Encode(info)
-
-	log.Error().Err(err).Stack().Msg("Response error")
 }
 
 func (rtr *Routing) newRequestFromChi(r *http.Request) (ConfigurationRequest, url.Values, error) {
@@ -219,12 +257,7 @@ func (rtr *Routing) enableOTelForRouter(r chi.Router) error {
 func (rtr *Routing) newResolver(req ConfigurationRequest) Resolvable {
 	if rtr.resolverGetter == nil {
 		rtr.resolverGetter = func() Resolvable {
-			return &Resolver{
-				flattenedStructure: req.FlattenedIndexedLists,
-				templateConfig:     rtr.AppConfig.Gotemplate,
-				enableTrace:        rtr.AppConfig.Tracing.Enabled,
-				k8sResolver:        rtr.K8sResolver,
-			}
+			return NewResolver(req, rtr.AppConfig.Gotemplate, rtr.K8sResolver)
 		}
 	}
 
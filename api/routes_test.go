@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/GlintPay/gccs/auth"
 	"github.com/GlintPay/gccs/backend"
 	"github.com/GlintPay/gccs/backend/git"
 	"github.com/GlintPay/gccs/config"
@@ -72,7 +73,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts-production.yaml > accounts.yaml > application-production.yaml > application.yaml"},
@@ -114,7 +115,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts-production.yaml > accounts.yaml > application-production.yaml > application.yaml"},
@@ -128,7 +129,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts-production.yaml > accounts.yaml > application-production.yaml > application.yaml"},
@@ -142,7 +143,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"somethingelse"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"application-production.yaml > application.yaml"},
@@ -156,7 +157,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > application.yaml"},
@@ -170,7 +171,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"somethingelse"},
 				"X-Resolution-Profiles":                 []string{"local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"application.yaml"},
@@ -215,14 +216,14 @@ func Test_routesHierarchical(t *testing.T) {
 			method:     "GET",
 			url:        "/accounts/local?norefresh", // don't refresh git
 			statusCode: 200,
-			jsonOutput: `{"name":"accounts","profiles":["local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff":{"currencies":["DEF","GHI","JKL"],"val":"xxx"},"currencies":["USD","EUR","ABC"],"site":{"retries":0,"timeout":50,"url":"https://test.com"},"supportedCurrencies":{"ABC":{},"EUR":{},"GBP":{}}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutput: `{"name":"accounts","profiles":["local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff":{"currencies":["DEF","GHI","JKL"],"val":"xxx"},"currencies":["USD","EUR","ABC"],"site":{"retries":0,"timeout":50,"url":"https://test.com"},"supportedCurrencies":{"ABC":{},"EUR":{},"GBP":{}}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
 			headers:    http.Header{"Content-Type": []string{"application/json"}},
 		},
 		{
 			method:     "GET",
 			url:        "/accounts/other,local?norefresh", // don't refresh git
 			statusCode: 200,
-			jsonOutput: `{"name":"accounts","profiles":["other","local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff":{"currencies":["DEF","GHI","JKL"],"val":"xxx"},"currencies":["USD","EUR","ABC"],"site":{"retries":0,"timeout":50,"url":"https://test.com"},"supportedCurrencies":{"ABC":{},"EUR":{},"GBP":{}}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutput: `{"name":"accounts","profiles":["other","local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff":{"currencies":["DEF","GHI","JKL"],"val":"xxx"},"currencies":["USD","EUR","ABC"],"site":{"retries":0,"timeout":50,"url":"https://test.com"},"supportedCurrencies":{"ABC":{},"EUR":{},"GBP":{}}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
 			headers:    http.Header{"Content-Type": []string{"application/json"}},
 		},
 		{
@@ -233,7 +234,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"other,local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > application-other.yaml > application.yaml"},
@@ -247,7 +248,7 @@ func Test_routesHierarchical(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts,security"},
 				"X-Resolution-Profiles":                 []string{"other,local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > security.yaml > application-other.yaml > application.yaml"},
@@ -310,7 +311,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts-production.yaml > accounts.yaml > application-production.yaml > application.yaml"},
@@ -325,7 +326,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"somethingelse"},
 				"X-Resolution-Profiles":                 []string{"production"},
 				"X-Resolution-Precedencedisplaymessage": []string{"application-production.yaml > application.yaml"},
@@ -340,7 +341,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > application.yaml"},
@@ -354,7 +355,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"somethingelse"},
 				"X-Resolution-Profiles":                 []string{"local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"application.yaml"},
@@ -378,24 +379,24 @@ func Test_routesFlattened(t *testing.T) {
 			method:        "GET",
 			url:           "/accounts/local?norefresh", // don't refresh git
 			statusCode:    200,
-			jsonOutput:    `{"name":"accounts","profiles":["local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
-			jsonOutputAlt: `{"name":"accounts","profiles":["local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutput:    `{"name":"accounts","profiles":["local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutputAlt: `{"name":"accounts","profiles":["local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
 			headers:       http.Header{"Content-Type": []string{"application/json"}},
 		},
 		{
 			method:        "GET",
 			url:           "/accounts/other,local?resolve=false&norefresh", // don't refresh git
 			statusCode:    200,
-			jsonOutput:    `{"name":"accounts","profiles":["other","local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
-			jsonOutputAlt: `{"name":"accounts","profiles":["other","local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutput:    `{"name":"accounts","profiles":["other","local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutputAlt: `{"name":"accounts","profiles":["other","local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
 			headers:       http.Header{"Content-Type": []string{"application/json"}},
 		},
 		{
 			method:        "PATCH",                                         // NB With PATCH and resolve off, behaviour is identical to GET - is that confusing?
 			url:           "/accounts/other,local?resolve=false&norefresh", // don't refresh git
 			statusCode:    200,
-			jsonOutput:    `{"name":"accounts","profiles":["other","local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
-			jsonOutputAlt: `{"name":"accounts","profiles":["other","local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutput:    `{"name":"accounts","profiles":["other","local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":["DEF","GHI","JKL"],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
+			jsonOutputAlt: `{"name":"accounts","profiles":["other","local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0]":"DEF","accountstuff.currencies[1]":"GHI","accountstuff.currencies[2]":"JKL","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com","supportedCurrencies.ABC":{},"supportedCurrencies.EUR":{},"supportedCurrencies.GBP":{}}},{"name":"/application-other.yaml","source":{"a":"b1","c":"d2"}},{"name":"/application.yaml","source":{"a":"b","b":"c","c":"d"}}]}`,
 			headers:       http.Header{"Content-Type": []string{"application/json"}},
 		},
 		{
@@ -407,7 +408,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts"},
 				"X-Resolution-Profiles":                 []string{"other,local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > application-other.yaml > application.yaml"},
@@ -422,7 +423,7 @@ func Test_routesFlattened(t *testing.T) {
 			headers: http.Header{
 				"Content-Type":                          []string{"application/json"},
 				"X-Resolution-Version":                  []string{expectedVersion},
-				"X-Resolution-Label":                    []string{""},
+				"X-Resolution-Label":                    []string{"master"},
 				"X-Resolution-Name":                     []string{"accounts,security"},
 				"X-Resolution-Profiles":                 []string{"other,local"},
 				"X-Resolution-Precedencedisplaymessage": []string{"accounts.yaml > security.yaml > application-other.yaml > application.yaml"},
@@ -506,8 +507,8 @@ accountstuff:
 			method:        "GET",
 			url:           "/accounts/local?norefresh", // don't refresh git
 			statusCode:    200,
-			jsonOutput:    `{"name":"accounts","profiles":["local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":[{"country":"djibouti","name":"DEF","x":"yy"},{"country":"ghana","name":"GHI","x":"z"},{"country":"jersey","name":"JKL","x":"a"}],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com"}}]}`,
-			jsonOutputAlt: `{"name":"accounts","profiles":["local"],"label":"","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0].country":"djibouti","accountstuff.currencies[0].name":"DEF","accountstuff.currencies[0].x":"yy","accountstuff.currencies[1].country":"ghana","accountstuff.currencies[1].name":"GHI","accountstuff.currencies[1].x":"z","accountstuff.currencies[2].country":"jersey","accountstuff.currencies[2].name":"JKL","accountstuff.currencies[2].x":"a","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com"}}]}`,
+			jsonOutput:    `{"name":"accounts","profiles":["local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies":[{"country":"djibouti","name":"DEF","x":"yy"},{"country":"ghana","name":"GHI","x":"z"},{"country":"jersey","name":"JKL","x":"a"}],"accountstuff.val":"xxx","currencies":["USD","EUR","ABC"],"site.retries":0,"site.timeout":50,"site.url":"https://test.com"}}]}`,
+			jsonOutputAlt: `{"name":"accounts","profiles":["local"],"label":"master","version":"` + expectedVersion + `","state":"","propertySources":[{"name":"/accounts.yaml","source":{"accountstuff.currencies[0].country":"djibouti","accountstuff.currencies[0].name":"DEF","accountstuff.currencies[0].x":"yy","accountstuff.currencies[1].country":"ghana","accountstuff.currencies[1].name":"GHI","accountstuff.currencies[1].x":"z","accountstuff.currencies[2].country":"jersey","accountstuff.currencies[2].name":"JKL","accountstuff.currencies[2].x":"a","accountstuff.val":"xxx","currencies[0]":"USD","currencies[1]":"EUR","currencies[2]":"ABC","site.retries":0,"site.timeout":50,"site.url":"https://test.com"}}]}`,
 			headers:       http.Header{"Content-Type": []string{"application/json"}},
 		},
 	}
@@ -572,7 +573,8 @@ func Test_routesTraceEnabled(t *testing.T) {
 			assertSpan(t, sr.Ended()[0],
 				"loadConfiguration",
 				trace.SpanKindServer,
-				[]attribute.KeyValue{}...,
+				attribute.String("backend", "git"),
+				attribute.Int("order", 0),
 			)
 
 			assertSpan(t, sr.Ended()[1],
@@ -741,6 +743,128 @@ func Test_routesResponseErrorsViaResolutionErrors(t *testing.T) {
 	}
 }
 
+//goland:noinspection GoUnhandledErrorResult
+func Test_routesConditional(t *testing.T) {
+	gitDir, err := os.MkdirTemp("", "*")
+	assert.NoError(t, err)
+	defer os.Remove(gitDir)
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	assert.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	setUpFiles(t, gitDir, wt)
+
+	var backends backend.Backends
+	backends = append(backends, &git.Backend{
+		Repo: repo,
+	})
+
+	router, _ := setUpRouter(t, backends, false)
+
+	get := func(url string, ifNoneMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("/accounts/production?resolve=true&norefresh", "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+
+	notModified := get("/accounts/production?resolve=true&norefresh", `"other", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+	assert.Equal(t, etag, notModified.Header().Get("ETag"))
+
+	pretty := get("/accounts/production?resolve=true&norefresh&pretty=true", etag)
+	assert.Equal(t, http.StatusOK, pretty.Code)
+	assert.NotEqual(t, etag, pretty.Header().Get("ETag"))
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", `
+site:
+  url: https://changed.com
+`)
+
+	changed := get("/accounts/production?resolve=true&norefresh", etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+}
+
+//goland:noinspection GoUnhandledErrorResult
+func Test_routesForbidden(t *testing.T) {
+	gitDir, err := os.MkdirTemp("", "*")
+	assert.NoError(t, err)
+	defer os.Remove(gitDir)
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	assert.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	setUpFiles(t, gitDir, wt)
+
+	var backends backend.Backends
+	backends = append(backends, &git.Backend{
+		Repo: repo,
+	})
+
+	router, routing := setUpRouter(t, backends, false)
+	routing.Authorizer = onlyProfile("staging")
+
+	tests := []ExampleRequest{
+		{
+			method:     "GET",
+			url:        "/accounts/production?resolve=true&norefresh",
+			statusCode: 403,
+			jsonOutput: `{"message":"[tester] may not read profile [production]"}`,
+		},
+		{
+			method:     "PATCH",
+			url:        "/accounts/production?resolve=true&norefresh",
+			body:       strings.NewReader(`{}`),
+			statusCode: 403,
+			jsonOutput: `{"message":"[tester] may not read profile [production]"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			validateRequest(t, tt, tt.jsonOutput, router, "")
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/staging?resolve=true&norefresh", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Limited when authenticated, whatever the Authorizer allows
+	limited := auth.WithPrincipal(context.Background(), auth.Principal{Name: "payments/payments-api", Applications: []string{"payments"}})
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/staging?resolve=true&norefresh", nil).WithContext(limited))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"message":"[payments/payments-api] may not read application [accounts]"}`, rr.Body.String())
+}
+
+type onlyProfile string
+
+func (p onlyProfile) Authorize(ctxt context.Context, _ []string, profiles []string, _ string) (context.Context, error) {
+	for _, each := range profiles {
+		if each != string(p) {
+			return ctxt, &auth.DeniedError{Reason: fmt.Sprintf("[tester] may not read profile [%s]", each)}
+		}
+	}
+	return ctxt, nil
+}
+
 type badResolver struct {
 }
 
@@ -759,6 +883,11 @@ func validateRequest(t *testing.T, tt ExampleRequest, jsonOutput string, router
 	assert.Equal(t, tt.statusCode, rr.Code)
 	assert.Equal(t, jsonOutput, strings.TrimSpace(rr.Body.String()))
 
+	if tt.method == http.MethodGet && tt.statusCode == http.StatusOK {
+		assert.Regexp(t, `^"[0-9a-f]{32}"$`, rr.Header().Get("ETag"))
+		rr.Header().Del("ETag")
+	}
+
 	if tt.headers != nil {
 		assert.Equal(t, tt.headers, rr.Header())
 	}
@@ -22,4 +22,5 @@ type ResolvedConfigValues map[string]any
 
 type ResolutionMetadata struct {
 	PrecedenceDisplayMessage string
+	UsesK8s                  bool // so may change without the backends changing
 }
@@ -7,6 +7,8 @@ type Source struct {
 	Version         string           `json:"version"`
 	State           string           `json:"state"`
 	PropertySources []PropertySource `json:"propertySources"`
+
+	Stale bool `json:"-"`
 }
 
 // PropertySource is the property source for the application.
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/GlintPay/gccs/utils"
+)
+
+// WatchEvent is sent whenever the resolved output for an application / profiles / label changes
+type WatchEvent struct {
+	Version     string   `json:"version"`               // of the resolved output, so also reflects changed K8s values
+	Commit      string   `json:"commit"`                // the backend version(s)
+	Label       string   `json:"label"`                 // as actually used
+	ChangedKeys []string `json:"changedKeys,omitempty"` // only with `?keys=true`, and once there's something to compare with
+}
+
+type watchState struct {
+	event  WatchEvent
+	values map[string]any
+}
+
+// watchHandler reports changes to what `/{application}/{profiles}[/{label}]?resolve=true` returns, with any label
+// passed as `?label=`:
+//   - as Server-Sent Events, starting with the current version unless it matches `Last-Event-ID`
+//   - with `?since=<version>`, as a long-poll, returning once the version differs, else `304 Not Modified`
+func (rtr *Routing) watchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, queries, err := rtr.newRequestFromChi(r)
+		if err != nil {
+			rtr.writeError(w, err)
+			return
+		}
+
+		if label := queries.Get("label"); label != "" {
+			if rtr.AppConfig.Git.DisableLabels {
+				rtr.writeError(w, fmt.Errorf("cannot specify a label when `git.disableLabels` is true"))
+				return
+			}
+			req.Labels = LabelsRequest{Branch: label}
+		}
+
+		r, err = rtr.authorize(r, req)
+		if err != nil {
+			rtr.writeError(w, err)
+			return
+		}
+
+		// Watches outlive the server's write timeout, ending instead on `StopWatches`
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		withKeys := overrideBooleanDefault(queries.Get("keys"), false)
+		cfg := rtr.AppConfig.Watch.Validate()
+
+		if queries.Has("since") {
+			rtr.longPoll(w, r, req, queries.Get("since"), withKeys, cfg.MaxWaitMillis, cfg.PollIntervalMillis)
+			return
+		}
+		rtr.stream(w, r, req, r.Header.Get("Last-Event-ID"), withKeys, cfg.PollIntervalMillis, cfg.HeartbeatMillis)
+	}
+}
+
+func (rtr *Routing) longPoll(w http.ResponseWriter, r *http.Request, req ConfigurationRequest, since string, withKeys bool, maxWaitMillis int64, pollMillis int64) {
+	initial, updates, unsubscribe, err := rtr.subscribe(r.Context(), req, pollMillis)
+	if err != nil {
+		rtr.writeError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	deadline := time.NewTimer(time.Duration(maxWaitMillis) * time.Millisecond)
+	defer deadline.Stop()
+
+	current := initial
+	for current.event.Version == since {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-rtr.stopWatches:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case current = <-updates:
+		}
+	}
+
+	bytes, err := json.Marshal(current.since(initial, withKeys))
+	rtr.handleOutput(w, err, bytes, req.LogResponses)
+}
+
+func (rtr *Routing) stream(w http.ResponseWriter, r *http.Request, req ConfigurationRequest, lastEventId string, withKeys bool, pollMillis int64, heartbeatMillis int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		rtr.writeError(w, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	current, updates, unsubscribe, err := rtr.subscribe(r.Context(), req, pollMillis)
+	if err != nil {
+		rtr.writeError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if current.event.Version != lastEventId {
+		writeEvent(w, current.event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(time.Duration(heartbeatMillis) * time.Millisecond)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-rtr.stopWatches:
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case next := <-updates:
+			writeEvent(w, next.since(current, withKeys))
+			flusher.Flush()
+			current = next
+		}
+	}
+}
+
+// StopWatches ends every watch, present and future, so that the server can shut down without waiting for them. Clients
+// are expected to reconnect, e.g. to another instance.
+func (rtr *Routing) StopWatches() {
+	rtr.stopWatchesOnce.Do(func() {
+		close(rtr.stopWatches)
+	})
+}
+
+func writeEvent(w http.ResponseWriter, event WatchEvent) {
+	bytes, _ := json.Marshal(event)
+	_, _ = fmt.Fprintf(w, "id: %s\nevent: change\ndata: %s\n\n", event.Version, bytes)
+}
+
+// watchState is what the request currently resolves to, read through the output cache, so that polls cost little while
+// neither the backends nor any K8s values have changed
+func (rtr *Routing) watchState(ctxt context.Context, req ConfigurationRequest) (*watchState, error) {
+	source, states, err := loadStates(ctxt, rtr.Backends, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := rtr.loadOutput(ctxt, req, true, source, states)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(result.body) // map keys are sorted, so this is stable
+
+	var values map[string]any
+	if e := json.Unmarshal(result.body, &values); e != nil {
+		return nil, e
+	}
+
+	return &watchState{
+		event: WatchEvent{
+			Version: hex.EncodeToString(digest[:16]),
+			Commit:  source.Version,
+			Label:   source.Label,
+		},
+		values: utils.Flatten(values, joinerFunc),
+	}, nil
+}
+
+// since is the event for this state, listing what changed from a previous one if asked to
+func (s *watchState) since(previous *watchState, withKeys bool) WatchEvent {
+	event := s.event
+	if withKeys && previous != nil && previous.event.Version != s.event.Version {
+		event.ChangedKeys = changedKeys(previous.values, s.values)
+	}
+	return event
+}
+
+func changedKeys(before map[string]any, after map[string]any) []string {
+	var changed []string
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+
+	slices.Sort(changed)
+	return changed
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlintPay/gccs/auth"
+	"github.com/rs/zerolog/log"
+)
+
+// watchPollers share one poll between every watch of the same request, however many clients are connected
+type watchPollers struct {
+	lock    sync.Mutex
+	pollers map[string]*watchPoller
+}
+
+type watchPoller struct {
+	ready   chan struct{} // closed once the first state, or error, is known
+	current *watchState
+	err     error
+
+	subscribers map[chan *watchState]struct{} // guarded by watchPollers.lock, as is current after ready
+	cancel      context.CancelFunc
+}
+
+func newWatchPollers() *watchPollers {
+	return &watchPollers{pollers: map[string]*watchPoller{}}
+}
+
+// subscribe returns the current state, then each new one on the channel, until unsubscribed. The first subscriber to a
+// request starts its poll, and the last to leave stops it.
+func (rtr *Routing) subscribe(ctxt context.Context, req ConfigurationRequest, pollMillis int64) (*watchState, <-chan *watchState, func(), error) {
+	// Only what affects the output distinguishes one watch from another
+	req.PrettyPrintJson = false
+	req.LogResponses = false
+	req.EnableTrace = false
+
+	key := watchKey(req, auth.MayResolveK8sSecrets(ctxt))
+	updates := make(chan *watchState, 1)
+
+	pollers := rtr.watches
+	pollers.lock.Lock()
+	poller, found := pollers.pollers[key]
+	if !found {
+		// Polls outlive whichever request started them, but still resolve with its permissions, which the key covers
+		pollCtxt, cancel := context.WithCancel(context.WithoutCancel(ctxt))
+		poller = &watchPoller{ready: make(chan struct{}), subscribers: map[chan *watchState]struct{}{}, cancel: cancel}
+		pollers.pollers[key] = poller
+
+		go rtr.poll(pollCtxt, key, poller, req, pollMillis)
+	}
+	poller.subscribers[updates] = struct{}{}
+	pollers.lock.Unlock()
+
+	unsubscribe := func() {
+		pollers.lock.Lock()
+		defer pollers.lock.Unlock()
+
+		delete(poller.subscribers, updates)
+		if len(poller.subscribers) == 0 && pollers.pollers[key] == poller {
+			delete(pollers.pollers, key)
+			poller.cancel()
+		}
+	}
+
+	select {
+	case <-poller.ready:
+	case <-ctxt.Done():
+		unsubscribe()
+		return nil, nil, nil, ctxt.Err()
+	}
+
+	if poller.err != nil {
+		unsubscribe()
+		return nil, nil, nil, poller.err
+	}
+
+	pollers.lock.Lock()
+	current := poller.current
+	pollers.lock.Unlock()
+
+	return current, updates, unsubscribe, nil
+}
+
+func (rtr *Routing) poll(ctxt context.Context, key string, poller *watchPoller, req ConfigurationRequest, pollMillis int64) {
+	pollers := rtr.watches
+
+	poller.current, poller.err = rtr.watchState(ctxt, req)
+	if poller.err != nil {
+		// Nothing to poll, so later subscribers start again
+		pollers.lock.Lock()
+		if pollers.pollers[key] == poller {
+			delete(pollers.pollers, key)
+		}
+		pollers.lock.Unlock()
+	}
+	close(poller.ready)
+
+	if poller.err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(pollMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctxt.Done():
+			return
+		case <-ticker.C:
+			next, e := rtr.watchState(ctxt, req)
+			if e != nil {
+				if ctxt.Err() == nil {
+					log.Warn().Err(e).Msgf("Watch of %v / %v failed, will retry", req.Applications, req.Profiles)
+				}
+				continue
+			}
+
+			pollers.lock.Lock()
+			if next.event.Version != poller.current.event.Version {
+				poller.current = next
+				for each := range poller.subscribers {
+					// Only the latest state matters, so replace any the subscriber hasn't yet taken
+					select {
+					case <-each:
+					default:
+					}
+					each <- next
+				}
+			}
+			pollers.lock.Unlock()
+		}
+	}
+}
+
+func watchKey(req ConfigurationRequest, k8sSecrets bool) string {
+	return strings.Join([]string{
+		req.Labels.Branch,
+		strings.Join(req.Applications, ","),
+		strings.Join(req.Profiles, ","),
+		strconv.FormatBool(k8sSecrets),
+		strconv.FormatBool(req.RefreshBackend),
+		strconv.FormatBool(req.FlattenHierarchies),
+		strconv.FormatBool(req.FlattenedIndexedLists),
+	}, "\x00")
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/backend/git"
+	"github.com/GlintPay/gccs/config"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchLongPoll(t *testing.T) {
+	gitDir := t.TempDir()
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	require.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "site:\n  url: https://test.com\n  timeout: 50\n")
+
+	router, routing := setUpRouter(t, backend.Backends{&git.Backend{Repo: repo}}, false)
+	routing.AppConfig.Watch = config.Watch{PollIntervalMillis: 10, MaxWaitMillis: 100}
+
+	poll := func(since string) (int, WatchEvent) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/production/watch?norefresh&keys=true&since="+since, nil))
+
+		var event WatchEvent
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &event))
+		}
+		return rr.Code, event
+	}
+
+	code, first := poll("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, first.Version, 32)
+	assert.Equal(t, _getHash(repo), first.Commit)
+	assert.Equal(t, "master", first.Label)
+	assert.Nil(t, first.ChangedKeys)
+
+	code, _ = poll(first.Version)
+	assert.Equal(t, http.StatusNotModified, code)
+
+	// Changes to files that aren't used don't count
+	_writeGitFile(t, gitDir, wt, "payments.yaml", "a: b")
+
+	code, _ = poll(first.Version)
+	assert.Equal(t, http.StatusNotModified, code)
+
+	routing.AppConfig.Watch.MaxWaitMillis = 5000
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_writeGitFile(t, gitDir, wt, "accounts.yaml", "site:\n  url: https://live.com\n  timeout: 50\n  retries: 3\n")
+	}()
+
+	code, second := poll(first.Version)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, first.Version, second.Version)
+	assert.Equal(t, []string{"site.retries", "site.url"}, second.ChangedKeys)
+}
+
+func TestWatchStream(t *testing.T) {
+	gitDir := t.TempDir()
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	require.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: b\n")
+
+	router, routing := setUpRouter(t, backend.Backends{&git.Backend{Repo: repo}}, false)
+	routing.AppConfig.Watch = config.Watch{PollIntervalMillis: 10}
+
+	// Streams outlive the write timeout
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/accounts/production/watch?norefresh&keys=true")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, WatchEvent) {
+		var id string
+		var event WatchEvent
+		for {
+			line, e := reader.ReadString('\n')
+			require.NoError(t, e)
+
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			case line == "" && id != "":
+				return id, event
+			}
+		}
+	}
+
+	id, first := readEvent()
+	assert.Equal(t, first.Version, id)
+	assert.Nil(t, first.ChangedKeys)
+
+	time.Sleep(100 * time.Millisecond)
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: c\n")
+
+	id, second := readEvent()
+	assert.Equal(t, second.Version, id)
+	assert.NotEqual(t, first.Version, second.Version)
+	assert.Equal(t, []string{"a"}, second.ChangedKeys)
+
+	// Ended, e.g. on shutdown
+	routing.StopWatches()
+
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+}
+
+func TestWatchesSharePolls(t *testing.T) {
+	gitDir := t.TempDir()
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	require.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: b\n")
+
+	counted := &countingBackend{Backend: &git.Backend{Repo: repo}}
+	router, routing := setUpRouter(t, backend.Backends{counted}, false)
+	routing.AppConfig.Watch = config.Watch{PollIntervalMillis: 20}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	const watchers = 5
+
+	var readers []*bufio.Reader
+	for range watchers {
+		resp, e := http.Get(server.URL + "/accounts/production/watch?norefresh&keys=true")
+		require.NoError(t, e)
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		_readEventData(t, reader)
+		readers = append(readers, reader)
+	}
+
+	// One poll for all, not one each
+	before := counted.calls.Load()
+	time.Sleep(200 * time.Millisecond)
+	assert.LessOrEqual(t, counted.calls.Load()-before, int64(11))
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: c\n")
+
+	for _, each := range readers {
+		assert.Equal(t, []string{"a"}, _readEventData(t, each).ChangedKeys)
+	}
+
+	// Stops once nobody is watching
+	server.CloseClientConnections()
+	assert.Eventually(t, func() bool {
+		routing.watches.lock.Lock()
+		defer routing.watches.lock.Unlock()
+		return len(routing.watches.pollers) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStopWatchesEndsLongPolls(t *testing.T) {
+	gitDir := t.TempDir()
+
+	repo, err := goGit.PlainInit(gitDir, false)
+	require.NoError(t, err)
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_writeGitFile(t, gitDir, wt, "accounts.yaml", "a: b\n")
+
+	router, routing := setUpRouter(t, backend.Backends{&git.Backend{Repo: repo}}, false)
+	routing.AppConfig.Watch = config.Watch{PollIntervalMillis: 10, MaxWaitMillis: 60_000}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/production/watch?norefresh&since=", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var current WatchEvent
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &current))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		routing.StopWatches()
+	}()
+
+	started := time.Now()
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/production/watch?norefresh&since="+current.Version, nil))
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
+
+type countingBackend struct {
+	backend.Backend
+	calls atomic.Int64
+}
+
+func (c *countingBackend) GetCurrentState(ctxt context.Context, applications []string, profiles []string, branch string, refresh bool) (*backend.State, error) {
+	c.calls.Add(1)
+	return c.Backend.GetCurrentState(ctxt, applications, profiles, branch, refresh)
+}
+
+func _readEventData(t *testing.T, reader *bufio.Reader) WatchEvent {
+	for {
+		line, e := reader.ReadString('\n')
+		require.NoError(t, e)
+
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var event WatchEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			return event
+		}
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	assert.Equal(t, []string{"added", "changed", "removed"}, changedKeys(
+		map[string]any{"same": 1, "changed": 1, "removed": 1},
+		map[string]any{"same": 1, "changed": 2, "added": 1},
+	))
+	assert.Nil(t, changedKeys(map[string]any{"a": []any{1}}, map[string]any{"a": []any{1}}))
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/GlintPay/gccs/cache"
+	"github.com/GlintPay/gccs/config"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errMissingCredentials     = errors.New("missing credentials")
+	errUnsupportedScheme      = errors.New("unsupported authorization scheme")
+	errInvalidCredentials     = errors.New("invalid credentials")
+	errUnparseableCredentials = errors.New("unparseable credentials")
+)
+
+// New returns nil if no authentication is configured
+func New(cfg config.Auth, opts ...Option) (*Authenticator, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	a := &Authenticator{config: cfg}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if cfg.Jwt.Enabled() {
+		a.jwtConfig = cfg.Jwt.Validate()
+
+		keys, err := loadJwtKeys(a.jwtConfig)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		a.jwtKeys = keys
+	}
+
+	if cfg.Kubernetes.Enabled {
+		if a.reviewer == nil {
+			return nil, errors.New("kubernetes: no K8s client to review tokens")
+		}
+		a.k8sConfig = cfg.Kubernetes.Validate()
+		a.reviewed = cache.New[[sha256.Size]byte, Principal]("tokenreviews", maxReviewedTokens)
+	}
+
+	return a, nil
+}
+
+// Middleware rejects unauthenticated requests with `401 Unauthorized`, otherwise passing on the Principal in the
+// request context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Rejected %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			a.writeUnauthorized(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if a.config.ClientCertificates {
+			if principal, ok := a.authenticateCertificate(r); ok {
+				return principal, nil
+			}
+		}
+		return Principal{}, errMissingCredentials
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if len(a.config.Basic.Users) > 0 {
+			return a.authenticateBasic(r)
+		}
+	case "bearer":
+		if looksLikeJwt(credentials) {
+			if a.jwtConfig.Enabled() && (!a.k8sConfig.Enabled || a.issuedForJwt(credentials)) {
+				return a.authenticateJwt(credentials)
+			}
+			if a.k8sConfig.Enabled {
+				return a.authenticateKubernetes(r.Context(), credentials)
+			}
+		}
+		if len(a.config.Bearer.Tokens) > 0 {
+			return a.authenticateBearer(credentials)
+		}
+	}
+	return Principal{}, errUnsupportedScheme
+}
+
+func (a *Authenticator) writeUnauthorized(w http.ResponseWriter, err error) {
+	if len(a.config.Basic.Users) > 0 {
+		realm := a.config.Basic.Realm
+		if realm == "" {
+			realm = "gccs"
+		}
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm))
+	}
+	if len(a.config.Bearer.Tokens) > 0 || a.jwtConfig.Enabled() || a.k8sConfig.Enabled {
+		w.Header().Add("WWW-Authenticate", "Bearer")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]any{"message": err.Error()})
+}
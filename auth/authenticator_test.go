@@ -0,0 +1,449 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNotConfigured(t *testing.T) {
+	a, err := New(config.Auth{})
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+}
+
+func TestBasic(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	a, err := New(config.Auth{Basic: config.BasicAuth{Users: map[string]string{"alice": string(hash)}}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		wantErr  string
+	}{
+		{name: "valid", user: "alice", password: "s3cret"},
+		{name: "valid again, once verified", user: "alice", password: "s3cret"},
+		{name: "wrong password", user: "alice", password: "guess", wantErr: "invalid credentials"},
+		{name: "unknown user", user: "bob", password: "s3cret", wantErr: "invalid credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.SetBasicAuth(tt.user, tt.password)
+
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, Principal{Name: "alice", Method: methodBasic}, principal)
+		})
+	}
+}
+
+func TestBearer(t *testing.T) {
+	a, err := New(config.Auth{Bearer: config.BearerAuth{Tokens: map[string]string{"ci": "abc123", "deployer": "def456"}}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		header  string
+		want    Principal
+		wantErr string
+	}{
+		{header: "Bearer def456", want: Principal{Name: "deployer", Method: methodBearer}},
+		{header: "bearer abc123", want: Principal{Name: "ci", Method: methodBearer}},
+		{header: "Bearer abc", wantErr: "invalid credentials"},
+		{header: "Bearer ", wantErr: "invalid credentials"},
+		{header: "", wantErr: "missing credentials"},
+		{header: "Basic YTpi", wantErr: "unsupported authorization scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", tt.header)
+
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, principal)
+		})
+	}
+}
+
+func TestJwt(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: rsaKey, KeyID: "rsa-1", Algorithm: string(jose.RS256), Use: "sig"}, // private keys are reduced to public
+	}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(dir, "ec.pem")
+	require.NoError(t, os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	a, err := New(config.Auth{Jwt: config.JwtAuth{
+		JwksFile:       jwksFile,
+		PublicKeyFiles: []string{pemFile},
+		Issuer:         "https://issuer",
+		Audience:       "gccs",
+	}})
+	require.NoError(t, err)
+
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   "https://issuer",
+		Subject:  "payments",
+		Audience: jwt.Audience{"other", "gccs"},
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	tests := []struct {
+		name    string
+		alg     jose.SignatureAlgorithm
+		key     any
+		kid     string
+		claims  func(c jwt.Claims) jwt.Claims
+		wantErr string
+	}{
+		{name: "jwks", alg: jose.RS256, key: rsaKey, kid: "rsa-1"},
+		{name: "jwks without kid", alg: jose.RS256, key: rsaKey},
+		{name: "pem", alg: jose.ES256, key: ecKey},
+		{name: "unknown kid", alg: jose.RS256, key: rsaKey, kid: "rsa-2", wantErr: "invalid JWT signature"},
+		{name: "wrong key", alg: jose.RS256, key: otherKey, wantErr: "invalid JWT signature"},
+		{name: "expired", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Expiry = jwt.NewNumericDate(now.Add(-2 * time.Minute))
+			return c
+		}, wantErr: "invalid JWT: go-jose/go-jose/jwt: validation failed, token is expired (exp)"},
+		{name: "expired within leeway", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Expiry = jwt.NewNumericDate(now.Add(-30 * time.Second))
+			return c
+		}},
+		{name: "no expiry", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Expiry = nil
+			return c
+		}, wantErr: "JWT has no expiry"},
+		{name: "wrong issuer", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Issuer = "https://elsewhere"
+			return c
+		}, wantErr: "invalid JWT: go-jose/go-jose/jwt: validation failed, invalid issuer claim (iss)"},
+		{name: "wrong audience", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Audience = jwt.Audience{"other"}
+			return c
+		}, wantErr: "invalid JWT: go-jose/go-jose/jwt: validation failed, invalid audience claim (aud)"},
+		{name: "no subject", alg: jose.RS256, key: rsaKey, claims: func(c jwt.Claims) jwt.Claims {
+			c.Subject = ""
+			return c
+		}, wantErr: "JWT has no `sub` claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid
+			if tt.claims != nil {
+				claims = tt.claims(claims)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+_sign(t, tt.alg, tt.key, tt.kid, claims))
+
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "payments", principal.Name)
+			assert.Equal(t, methodJwt, principal.Method)
+			assert.Equal(t, "https://issuer", principal.Claims["iss"])
+		})
+	}
+
+	t.Run("unparseable", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer a.b.c")
+
+		_, err := a.Authenticate(r)
+		assert.ErrorIs(t, err, errUnparseableCredentials)
+	})
+}
+
+func TestJwtKeysRequired(t *testing.T) {
+	dir := t.TempDir()
+	jwksFile := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, []byte(`{"keys":[]}`), 0600))
+
+	_, err := New(config.Auth{Jwt: config.JwtAuth{JwksFile: jwksFile}})
+	assert.EqualError(t, err, "jwt: no signing keys found")
+
+	_, err = New(config.Auth{Jwt: config.JwtAuth{PublicKeyFiles: []string{jwksFile}}})
+	assert.ErrorContains(t, err, "jwt: no PEM data in")
+}
+
+func TestKubernetes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	sign := func(issuer string) string {
+		return _sign(t, jose.RS256, rsaKey, "", jwt.Claims{Issuer: issuer, Subject: "x", Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	}
+	k8sToken, otherToken, userToken := sign("https://kubernetes.default.svc"), sign("https://elsewhere"), sign("https://kubernetes.default.svc/user")
+
+	reviewer := &fakeReviewer{users: map[string]string{
+		k8sToken:   "system:serviceaccount:payments:payments-api",
+		otherToken: "system:serviceaccount:monitoring:prober",
+		userToken:  "alice",
+	}}
+
+	_, err = New(config.Auth{Kubernetes: config.KubernetesAuth{Enabled: true}})
+	assert.EqualError(t, err, "kubernetes: no K8s client to review tokens")
+
+	a, err := New(config.Auth{Kubernetes: config.KubernetesAuth{
+		Enabled:   true,
+		Audiences: []string{"gccs"},
+		ServiceAccounts: map[string][]string{
+			"payments/*":            {"payments", "payments-*"},
+			"payments/payments-api": {"shared"},
+		},
+	}}, WithTokenReviewer(reviewer))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Principal
+		wantErr string
+	}{
+		{name: "service account", token: k8sToken, want: Principal{Name: "payments/payments-api", Method: methodKubernetes}},
+		{name: "cached", token: k8sToken, want: Principal{Name: "payments/payments-api", Method: methodKubernetes}},
+		{name: "unmapped service account", token: otherToken, wantErr: "service account [monitoring/prober] is not allowed"},
+		{name: "user", token: userToken, wantErr: "not a service account"},
+		{name: "rejected", token: sign("https://unknown"), wantErr: "invalid credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Name, principal.Name)
+			assert.Equal(t, tt.want.Method, principal.Method)
+			assert.ElementsMatch(t, []string{"payments", "payments-*", "shared"}, principal.Applications)
+		})
+	}
+
+	assert.Equal(t, 1, reviewer.calls[k8sToken])
+	assert.Equal(t, []string{"gccs"}, reviewer.audiences)
+
+	principal, err := a.Authenticate(_bearer(k8sToken))
+	require.NoError(t, err)
+	assert.NoError(t, principal.MayRead([]string{"payments-api", "shared"}))
+	assert.EqualError(t, principal.MayRead([]string{"payments", "accounts"}), "[payments/payments-api] may not read application [accounts]")
+
+	// Nothing listed, nothing allowed
+	none, err := New(config.Auth{Kubernetes: config.KubernetesAuth{Enabled: true}}, WithTokenReviewer(reviewer))
+	require.NoError(t, err)
+	_, err = none.Authenticate(_bearer(otherToken))
+	assert.EqualError(t, err, "service account [monitoring/prober] is not allowed")
+}
+
+func TestKubernetesAlongsideJwt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: rsaKey, Algorithm: string(jose.RS256)}}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	expiry := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	jwtToken := _sign(t, jose.RS256, rsaKey, "", jwt.Claims{Issuer: "https://issuer", Subject: "payments", Expiry: expiry})
+	k8sToken := _sign(t, jose.RS256, rsaKey, "", jwt.Claims{Issuer: "https://kubernetes.default.svc", Subject: "x", Expiry: expiry})
+
+	reviewer := &fakeReviewer{users: map[string]string{k8sToken: "system:serviceaccount:payments:payments-api"}}
+
+	a, err := New(config.Auth{
+		Jwt:        config.JwtAuth{JwksFile: jwksFile, Issuer: "https://issuer"},
+		Kubernetes: config.KubernetesAuth{Enabled: true, ServiceAccounts: map[string][]string{"*/*": nil}},
+	}, WithTokenReviewer(reviewer))
+	require.NoError(t, err)
+
+	principal, err := a.Authenticate(_bearer(jwtToken))
+	require.NoError(t, err)
+	assert.Equal(t, methodJwt, principal.Method)
+
+	principal, err = a.Authenticate(_bearer(k8sToken))
+	require.NoError(t, err)
+	assert.Equal(t, methodKubernetes, principal.Method)
+	assert.Nil(t, principal.Applications)
+	assert.NoError(t, principal.MayRead([]string{"anything"}))
+}
+
+type fakeReviewer struct {
+	users     map[string]string
+	calls     map[string]int
+	audiences []string
+}
+
+func (f *fakeReviewer) ReviewToken(_ context.Context, token string, audiences []string) (string, error) {
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[token]++
+	f.audiences = audiences
+
+	if user, ok := f.users[token]; ok {
+		return user, nil
+	}
+	return "", errors.New("token not authenticated")
+}
+
+func _bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestClientCertificate(t *testing.T) {
+	spiffe, err := url.Parse("spiffe://cluster.local/ns/payments/sa/payments-api")
+	require.NoError(t, err)
+
+	a, err := New(config.Auth{
+		ClientCertificates: true,
+		Bearer:             config.BearerAuth{Tokens: map[string]string{"ci": "abc123"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		state   *tls.ConnectionState
+		header  string
+		want    Principal
+		wantErr string
+	}{
+		{name: "common name", state: _verified(&x509.Certificate{Subject: pkix.Name{CommonName: "payments"}, DNSNames: []string{"payments.svc"}}), want: Principal{Name: "payments", Method: methodCertificate}},
+		{name: "uri", state: _verified(&x509.Certificate{URIs: []*url.URL{spiffe}}), want: Principal{Name: spiffe.String(), Method: methodCertificate}},
+		{name: "dns", state: _verified(&x509.Certificate{DNSNames: []string{"payments.svc"}}), want: Principal{Name: "payments.svc", Method: methodCertificate}},
+		{name: "unnamed", state: _verified(&x509.Certificate{}), wantErr: "missing credentials"},
+		{name: "unverified", state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "payments"}}}}, wantErr: "missing credentials"},
+		{name: "plain", wantErr: "missing credentials"},
+		{name: "header first", state: _verified(&x509.Certificate{Subject: pkix.Name{CommonName: "payments"}}), header: "Bearer abc123", want: Principal{Name: "ci", Method: methodBearer}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = tt.state
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			principal, err := a.Authenticate(r)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, principal)
+		})
+	}
+
+	// Only when enabled
+	b, err := New(config.Auth{Bearer: config.BearerAuth{Tokens: map[string]string{"ci": "abc123"}}})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = _verified(&x509.Certificate{Subject: pkix.Name{CommonName: "payments"}})
+	_, err = b.Authenticate(r)
+	assert.EqualError(t, err, "missing credentials")
+}
+
+func _verified(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := New(config.Auth{
+		Basic:  config.BasicAuth{Users: map[string]string{"alice": "$2a$04$invalid"}},
+		Bearer: config.BearerAuth{Tokens: map[string]string{"ci": "abc123"}},
+	})
+	require.NoError(t, err)
+
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromRequest(r)
+		assert.True(t, ok)
+		_, _ = w.Write([]byte(principal.Name))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/production", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, []string{`Basic realm="gccs", charset="UTF-8"`, "Bearer"}, rr.Header().Values("WWW-Authenticate"))
+	assert.JSONEq(t, `{"message":"missing credentials"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/accounts/production", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ci", rr.Body.String())
+}
+
+func _sign(t *testing.T, alg jose.SignatureAlgorithm, key any, kid string, claims jwt.Claims) string {
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader(jose.HeaderKey("kid"), kid)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
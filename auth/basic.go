@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Compared against for unknown users, so they take as long to reject as known ones
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown"), bcrypt.DefaultCost)
+
+func (a *Authenticator) authenticateBasic(r *http.Request) (Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, errUnparseableCredentials
+	}
+
+	digest := sha256.Sum256([]byte(password))
+	if verified, found := a.basicVerified.Load(username); found {
+		if subtle.ConstantTimeCompare(verified.([]byte), digest[:]) == 1 {
+			return Principal{Name: username, Method: methodBasic}, nil
+		}
+	}
+
+	hash, known := a.config.Basic.Users[username]
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+		return Principal{}, errInvalidCredentials
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return Principal{}, errInvalidCredentials
+	}
+
+	a.basicVerified.Store(username, digest[:])
+	return Principal{Name: username, Method: methodBasic}, nil
+}
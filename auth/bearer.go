@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"crypto/subtle"
+)
+
+// Every token is compared, so that timing reveals nothing about which nearly matched
+func (a *Authenticator) authenticateBearer(token string) (Principal, error) {
+	matched := ""
+	for name, each := range a.config.Bearer.Tokens {
+		if subtle.ConstantTimeCompare([]byte(each), []byte(token)) == 1 {
+			matched = name
+		}
+	}
+
+	if matched == "" || token == "" {
+		return Principal{}, errInvalidCredentials
+	}
+	return Principal{Name: matched, Method: methodBearer}, nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// A client certificate already verified during the TLS handshake, named by its common name, else its first URI
+// (e.g. a SPIFFE ID) or DNS name
+func (a *Authenticator) authenticateCertificate(r *http.Request) (Principal, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return Principal{}, false
+	}
+
+	name := certificateName(r.TLS.VerifiedChains[0][0])
+	if name == "" {
+		return Principal{}, false
+	}
+	return Principal{Name: name, Method: methodCertificate}, true
+}
+
+func certificateName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
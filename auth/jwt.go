@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// Only asymmetric algorithms, since the keys configured are public
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+var (
+	errJwtSignature = errors.New("invalid JWT signature")
+	errJwtExpiry    = errors.New("JWT has no expiry")
+)
+
+func looksLikeJwt(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (a *Authenticator) authenticateJwt(raw string) (Principal, error) {
+	token, err := jwt.ParseSigned(raw, jwtAlgorithms)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", errUnparseableCredentials, err)
+	}
+
+	header := token.Headers[0]
+
+	var claims jwt.Claims
+	var allClaims map[string]any
+
+	verified := false
+	for _, key := range a.jwtKeys {
+		if header.KeyID != "" && key.KeyID != "" && key.KeyID != header.KeyID {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+			continue
+		}
+		if token.Claims(key.Key, &claims, &allClaims) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Principal{}, errJwtSignature
+	}
+
+	if claims.Expiry == nil {
+		return Principal{}, errJwtExpiry
+	}
+
+	expected := jwt.Expected{Issuer: a.jwtConfig.Issuer, Time: time.Now()}
+	if a.jwtConfig.Audience != "" {
+		expected.AnyAudience = jwt.Audience{a.jwtConfig.Audience}
+	}
+	if e := claims.ValidateWithLeeway(expected, time.Duration(a.jwtConfig.LeewayMillis)*time.Millisecond); e != nil {
+		return Principal{}, fmt.Errorf("invalid JWT: %w", e)
+	}
+
+	subject, _ := allClaims[a.jwtConfig.SubjectClaim].(string)
+	if subject == "" {
+		return Principal{}, fmt.Errorf("JWT has no `%s` claim", a.jwtConfig.SubjectClaim)
+	}
+
+	return Principal{Name: subject, Method: methodJwt, Claims: allClaims}, nil
+}
+
+func loadJwtKeys(cfg config.JwtAuth) ([]jose.JSONWebKey, error) {
+	var keys []jose.JSONWebKey
+
+	if cfg.JwksFile != "" {
+		bs, err := os.ReadFile(cfg.JwksFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var jwks jose.JSONWebKeySet
+		if e := json.Unmarshal(bs, &jwks); e != nil {
+			return nil, fmt.Errorf("unparseable JWKS %s: %w", cfg.JwksFile, e)
+		}
+
+		for _, each := range jwks.Keys {
+			if each.Use != "" && each.Use != "sig" {
+				continue
+			}
+			if !each.IsPublic() {
+				each = each.Public()
+			}
+			keys = append(keys, each)
+		}
+	}
+
+	for _, each := range cfg.PublicKeyFiles {
+		key, err := readPublicKey(each)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jose.JSONWebKey{Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
+
+func readPublicKey(path string) (any, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, e := x509.ParseCertificate(block.Bytes)
+		if e != nil {
+			return nil, e
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM type [%s] in %s", block.Type, path)
+	}
+}
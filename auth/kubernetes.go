@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/rs/zerolog/log"
+)
+
+// TokenReviewer confirms Kubernetes tokens, returning the user each authenticates, as `k8s.Client` does
+type TokenReviewer interface {
+	ReviewToken(ctx context.Context, token string, audiences []string) (string, error)
+}
+
+const (
+	serviceAccountPrefix = "system:serviceaccount:"
+	maxReviewedTokens    = 10000
+)
+
+var errNotServiceAccount = errors.New("not a service account")
+
+func (a *Authenticator) authenticateKubernetes(ctxt context.Context, token string) (Principal, error) {
+	key := sha256.Sum256([]byte(token))
+	if principal, ok := a.reviewed.Get(key); ok {
+		return principal, nil
+	}
+
+	username, err := a.reviewer.ReviewToken(ctxt, token, a.k8sConfig.Audiences)
+	if err != nil {
+		log.Warn().Err(err).Msg("Kubernetes token review failed")
+		return Principal{}, errInvalidCredentials
+	}
+
+	serviceAccount, ok := strings.CutPrefix(username, serviceAccountPrefix)
+	if !ok {
+		return Principal{}, errNotServiceAccount
+	}
+	name := strings.Replace(serviceAccount, ":", "/", 1)
+
+	applications, allowed := a.serviceAccountApplications(name)
+	if !allowed {
+		return Principal{}, fmt.Errorf("service account [%s] is not allowed", name)
+	}
+
+	principal := Principal{Name: name, Method: methodKubernetes, Applications: applications}
+	a.reviewed.Put(key, principal, time.Duration(a.k8sConfig.CacheMillis)*time.Millisecond)
+	return principal, nil
+}
+
+// Combines the applications of every entry matching the service account, where nil means any. Service accounts
+// not listed are rejected, so none are allowed if none are listed.
+func (a *Authenticator) serviceAccountApplications(name string) ([]string, bool) {
+	var applications []string
+	allowed, unlimited := false, false
+	for pattern, each := range a.k8sConfig.ServiceAccounts {
+		if !matches([]string{pattern}, name) {
+			continue
+		}
+		allowed = true
+		unlimited = unlimited || len(each) == 0
+		applications = append(applications, each...)
+	}
+
+	if unlimited {
+		return nil, allowed
+	}
+	return applications, allowed
+}
+
+// Whether a JWT is for the configured issuer rather than Kubernetes, going by its unverified `iss` claim. Without an
+// issuer configured, it can't be told apart, so is assumed to be.
+func (a *Authenticator) issuedForJwt(raw string) bool {
+	if a.jwtConfig.Issuer == "" {
+		return true
+	}
+
+	token, err := jwt.ParseSigned(raw, jwtAlgorithms)
+	if err != nil {
+		return true
+	}
+
+	var claims jwt.Claims
+	if token.UnsafeClaimsWithoutVerification(&claims) != nil {
+		return true
+	}
+	return claims.Issuer == a.jwtConfig.Issuer
+}
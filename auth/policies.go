@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/GlintPay/gccs/utils"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// Policy grants the principals it names read access to matching applications, profiles and labels. Each list
+// holds `path.Match` patterns, where those starting `!` exclude, and an empty list allows anything. Admin endpoints
+// are only open to principals of a policy setting `admin`.
+type Policy struct {
+	Name         string   `json:"name"`
+	Principals   []string `json:"principals"`
+	Applications []string `json:"applications"`
+	Profiles     []string `json:"profiles"`
+	Labels       []string `json:"labels"`
+	K8sSecrets   bool     `json:"k8sSecrets"` // may `${k8s/secret:...}` placeholders be resolved
+	Admin        bool     `json:"admin"`      // may `/admin/reload` and `/webhooks/deliveries` be used
+}
+
+type policyFile struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Policies are read from a YAML file, which is reloaded whenever it changes
+type Policies struct {
+	file         string
+	defaultLabel string // checked against `labels` when a request names none
+	current      atomic.Pointer[[]Policy]
+
+	modTime time.Time
+	size    int64
+}
+
+// DeniedError explains why a request was refused
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// Grant is what an authorised request may do beyond reading configuration
+type Grant struct {
+	K8sSecrets bool
+}
+
+type grantKey struct{}
+
+// MayResolveK8sSecrets is true unless the request was authorised without that permission
+func MayResolveK8sSecrets(ctxt context.Context) bool {
+	grant, ok := ctxt.Value(grantKey{}).(Grant)
+	return !ok || grant.K8sSecrets
+}
+
+// LoadPolicies from the file, checking requests without a label as if for `defaultLabel`
+func LoadPolicies(file string, defaultLabel string) (*Policies, error) {
+	p := &Policies{file: file, defaultLabel: defaultLabel}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Authorize the principal in the context to read every application and profile requested, for each of a chain of
+// labels, returning a context carrying the Grant
+func (p *Policies) Authorize(ctxt context.Context, applications []string, profiles []string, labels string) (context.Context, error) {
+	principal, ok := FromContext(ctxt)
+	if !ok {
+		return ctxt, &DeniedError{Reason: "unauthenticated"}
+	}
+
+	requestedLabels := utils.SplitLabels(labels)
+	if len(requestedLabels) == 0 {
+		requestedLabels = []string{p.defaultLabel}
+	}
+
+	granted := false
+	grant := Grant{}
+	reason := ""
+
+	for _, each := range *p.current.Load() {
+		if !matchesPrincipal(each.Principals, principal.Name) {
+			continue
+		}
+
+		denial := each.denial(applications, profiles, requestedLabels)
+		if denial == "" {
+			granted = true
+			grant.K8sSecrets = grant.K8sSecrets || each.K8sSecrets
+		} else if reason == "" {
+			reason = denial
+		}
+	}
+
+	if !granted {
+		if reason == "" {
+			return ctxt, &DeniedError{Reason: fmt.Sprintf("no policy applies to [%s]", principal.Name)}
+		}
+		return ctxt, &DeniedError{Reason: fmt.Sprintf("[%s] may not read %s", principal.Name, reason)}
+	}
+
+	return context.WithValue(ctxt, grantKey{}, grant), nil
+}
+
+// AuthorizeAdmin allows the principal in the context only if a policy naming it sets `admin`. Without policies, nobody is
+// allowed.
+func (p *Policies) AuthorizeAdmin(ctxt context.Context) error {
+	principal, ok := FromContext(ctxt)
+	if !ok {
+		return &DeniedError{Reason: "unauthenticated"}
+	}
+
+	if p != nil {
+		for _, each := range *p.current.Load() {
+			if each.Admin && matchesPrincipal(each.Principals, principal.Name) {
+				return nil
+			}
+		}
+	}
+	return &DeniedError{Reason: fmt.Sprintf("[%s] may not use admin endpoints", principal.Name)}
+}
+
+// RequireAdmin refuses, with `403 Forbidden`, requests that AuthorizeAdmin doesn't allow
+func (p *Policies) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.AuthorizeAdmin(r.Context()); err != nil {
+			log.Warn().Err(err).Msgf("Forbidden %s %s", r.Method, r.URL.Path)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]any{"message": err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Watch for changes every `interval`, keeping the current policies if the new ones are invalid
+func (p *Policies) Watch(ctxt context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctxt.Done():
+				return
+			case <-ticker.C:
+				if e := p.reloadIfChanged(); e != nil {
+					log.Error().Err(e).Msgf("Keeping existing policies, as %s is invalid", p.file)
+				}
+			}
+		}
+	}()
+}
+
+func (p *Policies) reloadIfChanged() error {
+	info, err := os.Stat(p.file)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return nil
+	}
+	return p.reload()
+}
+
+func (p *Policies) reload() error {
+	info, err := os.Stat(p.file)
+	if err != nil {
+		return err
+	}
+
+	// Recorded even if invalid, so an error is only reported once per change
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+
+	bs, err := os.ReadFile(p.file)
+	if err != nil {
+		return err
+	}
+
+	var parsed policyFile
+	if e := yaml.UnmarshalStrict(bs, &parsed); e != nil {
+		return fmt.Errorf("unparseable policies %s: %w", p.file, e)
+	}
+
+	for _, each := range parsed.Policies {
+		if e := each.validate(); e != nil {
+			return e
+		}
+	}
+
+	p.current.Store(&parsed.Policies)
+
+	log.Info().Msgf("Loaded %d authorization policies from %s", len(parsed.Policies), p.file)
+	return nil
+}
+
+func (p Policy) validate() error {
+	if p.Name == "" {
+		return errors.New("every policy requires a name")
+	}
+	if len(p.Principals) == 0 {
+		return fmt.Errorf("policy [%s] has no principals", p.Name)
+	}
+
+	for _, patterns := range [][]string{p.Principals, p.Applications, p.Profiles, p.Labels} {
+		for _, each := range patterns {
+			if _, e := path.Match(strings.TrimPrefix(each, "!"), ""); e != nil {
+				return fmt.Errorf("policy [%s] has invalid pattern [%s]", p.Name, each)
+			}
+		}
+	}
+	return nil
+}
+
+// denial describes the first thing requested that isn't allowed, if any
+func (p Policy) denial(applications []string, profiles []string, labels []string) string {
+	for _, each := range applications {
+		if !matches(p.Applications, each) {
+			return fmt.Sprintf("application [%s]", each)
+		}
+	}
+	for _, each := range profiles {
+		if !matches(p.Profiles, each) {
+			return fmt.Sprintf("profile [%s]", each)
+		}
+	}
+	for _, each := range labels {
+		if !matches(p.Labels, each) {
+			return fmt.Sprintf("label [%s]", each)
+		}
+	}
+	return ""
+}
+
+// matchesPrincipal as for matches, but with `/` like any other character, so that `*` also matches K8s
+// `namespace/name` principals and certificates' URI names
+func matchesPrincipal(patterns []string, name string) bool {
+	unseparated := make([]string, len(patterns))
+	for i, each := range patterns {
+		unseparated[i] = strings.ReplaceAll(each, "/", "\x00")
+	}
+	return matches(unseparated, strings.ReplaceAll(name, "/", "\x00"))
+}
+
+// matches if no exclusion matches, and either there are no other patterns or one of them matches
+func matches(patterns []string, value string) bool {
+	included, hasInclusions := false, false
+	for _, each := range patterns {
+		if excluded, found := strings.CutPrefix(each, "!"); found {
+			if ok, _ := path.Match(excluded, value); ok {
+				return false
+			}
+			continue
+		}
+
+		hasInclusions = true
+		if ok, _ := path.Match(each, value); ok {
+			included = true
+		}
+	}
+	return included || !hasInclusions
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicies = `
+policies:
+  - name: payments
+    principals: [payments]
+    applications: [payments, "payments-*"]
+    k8sSecrets: true
+  - name: staging
+    principals: ["staging-*"]
+    profiles: ["!prod", "!prod-*"]
+    labels: [main, "release-*"]
+  - name: locked
+    principals: [locked]
+    labels: ["!prod"]
+  - name: readers
+    principals: [auditor]
+    applications: ["*"]
+  - name: operators
+    principals: ["ops-*"]
+    applications: ["!*"]
+    admin: true
+`
+
+func TestAuthorize(t *testing.T) {
+	policies := _writePolicies(t, testPolicies)
+
+	tests := []struct {
+		name         string
+		principal    string
+		applications []string
+		profiles     []string
+		labels       string
+		wantErr      string
+		wantSecrets  bool
+	}{
+		{name: "own application", principal: "payments", applications: []string{"payments"}, profiles: []string{"prod"}, wantSecrets: true},
+		{name: "own applications", principal: "payments", applications: []string{"payments", "payments-api"}, wantSecrets: true},
+		{name: "other application", principal: "payments", applications: []string{"payments", "accounts"}, wantErr: "[payments] may not read application [accounts]"},
+		{name: "staging profile", principal: "staging-ci", applications: []string{"accounts"}, profiles: []string{"staging"}},
+		{name: "prod profile", principal: "staging-ci", applications: []string{"accounts"}, profiles: []string{"staging", "prod"}, wantErr: "[staging-ci] may not read profile [prod]"},
+		{name: "prod-like profile", principal: "staging-ci", applications: []string{"accounts"}, profiles: []string{"prod-eu"}, wantErr: "[staging-ci] may not read profile [prod-eu]"},
+		{name: "no label is the default", principal: "staging-ci", applications: []string{"accounts"}, labels: ""},
+		{name: "label chain", principal: "staging-ci", applications: []string{"accounts"}, labels: "release-1,main"},
+		{name: "label outside chain", principal: "staging-ci", applications: []string{"accounts"}, labels: "feature-x,main", wantErr: "[staging-ci] may not read label [feature-x]"},
+		{name: "label with spaces", principal: "staging-ci", applications: []string{"accounts"}, labels: " main , release-1"},
+		{name: "label excluded with spaces", principal: "locked", applications: []string{"accounts"}, labels: " prod", wantErr: "[locked] may not read label [prod]"},
+		{name: "label excluded in chain", principal: "locked", applications: []string{"accounts"}, labels: "nope, prod", wantErr: "[locked] may not read label [prod]"},
+		{name: "label allowed in chain", principal: "locked", applications: []string{"accounts"}, labels: "nope,, main"},
+		{name: "no secrets", principal: "auditor", applications: []string{"accounts"}},
+		{name: "no policy", principal: "stranger", applications: []string{"accounts"}, wantErr: "no policy applies to [stranger]"},
+		{name: "admin only", principal: "ops-oncall", applications: []string{"accounts"}, wantErr: "[ops-oncall] may not read application [accounts]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctxt := WithPrincipal(context.Background(), Principal{Name: tt.principal})
+
+			granted, err := policies.Authorize(ctxt, tt.applications, tt.profiles, tt.labels)
+			if tt.wantErr != "" {
+				var denied *DeniedError
+				require.ErrorAs(t, err, &denied)
+				assert.Equal(t, tt.wantErr, denied.Reason)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSecrets, MayResolveK8sSecrets(granted))
+		})
+	}
+
+	_, err := policies.Authorize(context.Background(), []string{"accounts"}, nil, "")
+	assert.EqualError(t, err, "unauthenticated")
+}
+
+func TestMatchesPrincipal(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{patterns: []string{"*"}, name: "system/sa", want: true},
+		{patterns: []string{"*"}, name: "spiffe://cluster.local/ns/payments/sa/api", want: true},
+		{patterns: []string{"system/*"}, name: "system/sa", want: true},
+		{patterns: []string{"*/sa"}, name: "system/sa", want: true},
+		{patterns: []string{"*", "!system/*"}, name: "system/sa", want: false},
+		{patterns: []string{"other/*"}, name: "system/sa", want: false},
+		{patterns: []string{"staging-*"}, name: "staging-ci", want: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchesPrincipal(tt.patterns, tt.name), "%v %s", tt.patterns, tt.name)
+	}
+
+	policies := _writePolicies(t, "policies:\n  - name: everyone\n    principals: [\"*\"]\n")
+	_, err := policies.Authorize(WithPrincipal(context.Background(), Principal{Name: "system/sa"}), []string{"accounts"}, nil, "")
+	assert.NoError(t, err)
+}
+
+func TestAuthorizeDefaultLabel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.yml")
+	require.NoError(t, os.WriteFile(file, []byte(testPolicies), 0600))
+
+	policies, err := LoadPolicies(file, "prod")
+	require.NoError(t, err)
+
+	for principal, reason := range map[string]string{
+		"staging-ci": "[staging-ci] may not read label [prod]",
+		"locked":     "[locked] may not read label [prod]",
+	} {
+		_, err = policies.Authorize(WithPrincipal(context.Background(), Principal{Name: principal}), []string{"accounts"}, nil, "")
+		assert.EqualError(t, err, reason, principal)
+
+		_, err = policies.Authorize(WithPrincipal(context.Background(), Principal{Name: principal}), []string{"accounts"}, nil, " , ")
+		assert.EqualError(t, err, reason, principal)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	policies := _writePolicies(t, testPolicies)
+
+	tests := []struct {
+		name      string
+		policies  *Policies
+		principal string
+		code      int
+		message   string
+	}{
+		{name: "admin", policies: policies, principal: "ops-oncall", code: http.StatusOK},
+		{name: "reader", policies: policies, principal: "auditor", code: http.StatusForbidden, message: "[auditor] may not use admin endpoints"},
+		{name: "no policy", policies: policies, principal: "stranger", code: http.StatusForbidden, message: "[stranger] may not use admin endpoints"},
+		{name: "unauthenticated", policies: policies, code: http.StatusForbidden, message: "unauthenticated"},
+		{name: "no policies", principal: "ops-oncall", code: http.StatusForbidden, message: "[ops-oncall] may not use admin endpoints"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.policies.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest("POST", "/admin/reload", nil)
+			if tt.principal != "" {
+				req = req.WithContext(WithPrincipal(req.Context(), Principal{Name: tt.principal}))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.message != "" {
+				assert.JSONEq(t, `{"message":"`+tt.message+`"}`, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestMayResolveK8sSecretsWithoutPolicies(t *testing.T) {
+	assert.True(t, MayResolveK8sSecrets(context.Background()))
+}
+
+func TestPoliciesReload(t *testing.T) {
+	policies := _writePolicies(t, testPolicies)
+	ctxt := WithPrincipal(context.Background(), Principal{Name: "auditor"})
+
+	_, err := policies.Authorize(ctxt, []string{"accounts"}, nil, "")
+	require.NoError(t, err)
+
+	// Invalid changes are ignored
+	_rewrite(t, policies.file, "policies:\n  - name: broken\n    unknownField: true\n")
+	assert.ErrorContains(t, policies.reloadIfChanged(), "unknown field")
+
+	_, err = policies.Authorize(ctxt, []string{"accounts"}, nil, "")
+	assert.NoError(t, err)
+
+	_rewrite(t, policies.file, "policies:\n  - name: payments\n    principals: [payments]\n")
+	assert.NoError(t, policies.reloadIfChanged())
+
+	_, err = policies.Authorize(ctxt, []string{"accounts"}, nil, "")
+	assert.EqualError(t, err, "no policy applies to [auditor]")
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{name: "no name", contents: "policies:\n  - principals: [a]\n", wantErr: "every policy requires a name"},
+		{name: "no principals", contents: "policies:\n  - name: a\n", wantErr: "policy [a] has no principals"},
+		{name: "bad pattern", contents: "policies:\n  - name: a\n    principals: [a]\n    profiles: [\"!prod[\"]\n", wantErr: "policy [a] has invalid pattern [!prod[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policies.yml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.contents), 0600))
+
+			_, err := LoadPolicies(file, "main")
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func _writePolicies(t *testing.T, contents string) *Policies {
+	file := filepath.Join(t.TempDir(), "policies.yml")
+	require.NoError(t, os.WriteFile(file, []byte(contents), 0600))
+
+	policies, err := LoadPolicies(file, "main")
+	require.NoError(t, err)
+	return policies
+}
+
+// Also moves the modification time on, in case the filesystem's resolution is coarse
+func _rewrite(t *testing.T, file string, contents string) {
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(file, []byte(contents), 0600))
+
+	later := info.ModTime().Add(time.Second)
+	require.NoError(t, os.Chtimes(file, later, later))
+}
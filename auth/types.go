@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/GlintPay/gccs/cache"
+	"github.com/GlintPay/gccs/config"
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Principal is whoever a request was authenticated as
+type Principal struct {
+	Name   string         `json:"name"`
+	Method string         `json:"method"`           // basic, bearer, jwt, kubernetes or certificate
+	Claims map[string]any `json:"claims,omitempty"` // only for JWTs
+
+	Applications []string `json:"applications,omitempty"` // if set, all it may read, whatever the policies
+}
+
+// MayRead checks the applications against any limit placed on the principal when it authenticated
+func (p Principal) MayRead(applications []string) error {
+	for _, each := range applications {
+		if !matches(p.Applications, each) {
+			return &DeniedError{Reason: fmt.Sprintf("[%s] may not read application [%s]", p.Name, each)}
+		}
+	}
+	return nil
+}
+
+const (
+	methodBasic       = "basic"
+	methodBearer      = "bearer"
+	methodJwt         = "jwt"
+	methodKubernetes  = "kubernetes"
+	methodCertificate = "certificate"
+)
+
+// Authenticator checks each request's `Authorization` header against whichever methods are configured
+type Authenticator struct {
+	config config.Auth
+
+	basicVerified sync.Map // username to the SHA-256 of a password bcrypt already accepted, as bcrypt is slow
+
+	jwtConfig config.JwtAuth
+	jwtKeys   []jose.JSONWebKey
+
+	k8sConfig config.KubernetesAuth
+	reviewer  TokenReviewer
+	reviewed  *cache.LRU[[sha256.Size]byte, Principal] // by token hash, as each review is a K8s API call
+}
+
+// Option configures an Authenticator
+type Option func(*Authenticator)
+
+// WithTokenReviewer confirms Kubernetes tokens, as required if `kubernetes` is enabled
+func WithTokenReviewer(reviewer TokenReviewer) Option {
+	return func(a *Authenticator) {
+		a.reviewer = reviewer
+	}
+}
+
+type principalKey struct{}
+
+// FromContext returns the authenticated principal, if any
+func FromContext(ctxt context.Context) (Principal, bool) {
+	principal, ok := ctxt.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+func WithPrincipal(ctxt context.Context, principal Principal) context.Context {
+	return context.WithValue(ctxt, principalKey{}, principal)
+}
+
+func FromRequest(r *http.Request) (Principal, bool) {
+	return FromContext(r.Context())
+}
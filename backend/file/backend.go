@@ -23,7 +23,7 @@ func (s *Backend) Init(_ context.Context, appConfig config.ApplicationConfigurat
 	return nil
 }
 
-func (s *Backend) GetCurrentState(_ context.Context, branch string, _ bool) (*backend.State, error) {
+func (s *Backend) GetCurrentState(_ context.Context, _ []string, _ []string, branch string, _ bool) (*backend.State, error) {
 	if branch != "" {
 		return nil, errors.New("labels, multiple branches not supported by File backend")
 	}
@@ -37,6 +37,16 @@ func (s *Backend) GetCurrentState(_ context.Context, branch string, _ bool) (*ba
 	}, nil
 }
 
+func (s *Backend) Health(_ context.Context) (backend.Health, error) {
+	h := backend.Health{Location: s.Config.Path}
+
+	if _, err := os.ReadDir(s.Config.Path); err != nil {
+		h.LastError = err.Error()
+		return h, err
+	}
+	return h, nil
+}
+
 func (s *Backend) Close() {
 	// NOOP
 }
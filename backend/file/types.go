@@ -10,6 +10,10 @@ type Backend struct {
 	YamlContext filetypes.YamlContext
 }
 
+func (s *Backend) Name() string {
+	return "file"
+}
+
 func (s *Backend) Order() int {
 	return s.Config.Order
 }
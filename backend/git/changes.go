@@ -0,0 +1,114 @@
+package git
+
+import (
+	"slices"
+
+	"github.com/GlintPay/gccs/backend"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rs/zerolog/log"
+)
+
+// Subscribe to each branch update fetched, from any of our repositories. Not safe to call once serving.
+func (s *Backend) Subscribe(listener func(backend.Change)) {
+	s.listeners = append(s.listeners, listener)
+	for _, each := range s.repos {
+		each.backend.Subscribe(listener)
+	}
+}
+
+// The fetched commit of each branch, by name
+func (s *Backend) branchHeads(repo *goGit.Repository) map[string]plumbing.Hash {
+	if len(s.listeners) == 0 {
+		return nil
+	}
+
+	s.commitsLock.Lock()
+	defer s.commitsLock.Unlock()
+
+	heads := map[string]plumbing.Hash{}
+
+	refs, err := repo.References()
+	if err != nil {
+		return heads
+	}
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && ref.Name().IsRemote() {
+			heads[ref.Name().Short()[len(goGit.DefaultRemoteName)+1:]] = ref.Hash()
+		}
+		return nil
+	})
+	return heads
+}
+
+// Only updates to existing branches are reported, not new ones
+func (s *Backend) publishChanges(repo *goGit.Repository, before map[string]plumbing.Hash) {
+	if len(s.listeners) == 0 {
+		return
+	}
+
+	for branch, hash := range s.branchHeads(repo) {
+		previous, existed := before[branch]
+		if !existed || previous == hash {
+			continue
+		}
+
+		files, err := s.changedFiles(repo, previous, hash)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Cannot list files changed on [%s]", branch)
+		}
+
+		change := backend.Change{
+			Backend:         s.Name(),
+			Location:        s.location(),
+			Label:           branch,
+			PreviousVersion: previous.String(),
+			Version:         hash.String(),
+			Files:           files,
+		}
+
+		for _, listener := range s.listeners {
+			listener(change)
+		}
+	}
+}
+
+func (s *Backend) changedFiles(repo *goGit.Repository, from plumbing.Hash, to plumbing.Hash) ([]string, error) {
+	s.commitsLock.Lock()
+	defer s.commitsLock.Unlock()
+
+	fromTree, err := commitTree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, each := range changes {
+		for _, name := range []string{each.From.Name, each.To.Name} {
+			if name != "" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+
+	slices.Sort(files)
+	return files, nil
+}
+
+func commitTree(repo *goGit.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
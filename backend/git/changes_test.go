@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeToChanges(t *testing.T) {
+	ctxt := context.Background()
+
+	remoteDir := t.TempDir()
+	repo, first := _newRepoAt(t, remoteDir, time.Now().Add(-time.Hour))
+	_commitFile(t, remoteDir, repo, "accounts.yaml", "a: b")
+	second, err := repo.Head()
+	require.NoError(t, err)
+
+	b := &Backend{}
+	require.NoError(t, b.Init(ctxt, config.ApplicationConfiguration{Git: config.GitConfig{
+		Uri:          remoteDir,
+		Basedir:      t.TempDir(),
+		CloneOnStart: true,
+	}}))
+
+	var changes []backend.Change
+	b.Subscribe(func(c backend.Change) {
+		changes = append(changes, c)
+	})
+
+	// Nothing new
+	require.NoError(t, b.connect(ctxt, false, true))
+	assert.Empty(t, changes)
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(remoteDir, "application.yaml")))
+	_, err = wt.Remove("application.yaml")
+	require.NoError(t, err)
+	third := _commitFile(t, remoteDir, repo, "accounts-production.yaml", "c: d")
+
+	require.NoError(t, wt.Checkout(&goGit.CheckoutOptions{Branch: branchRef("feature"), Create: true}))
+	_commitFile(t, remoteDir, repo, "payments.yaml", "e: f")
+
+	require.NoError(t, b.connect(ctxt, false, true))
+
+	// New branches aren't reported
+	assert.Equal(t, []backend.Change{{
+		Backend:         "git",
+		Location:        remoteDir,
+		Label:           "master",
+		PreviousVersion: second.Hash().String(),
+		Version:         third,
+		Files:           []string{"accounts-production.yaml", "application.yaml"},
+	}}, changes)
+	assert.NotEqual(t, first, third)
+}
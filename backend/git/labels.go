@@ -0,0 +1,100 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GlintPay/gccs/utils"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	minHashPrefix = 4 // as per `git rev-parse`
+
+	allBranchesRefSpec = "refs/heads/*:refs/remotes/origin/*"
+	allTagsRefSpec     = "refs/tags/*:refs/tags/*"
+)
+
+var (
+	errUnknownLabel    = errors.New("no branch, tag or commit matches label")
+	errRepoUnavailable = errors.New("repository unavailable")
+)
+
+// Labels are tried in order, e.g. `feature-x,main`, returning the first that resolves along with its commit
+func (s *Backend) selectLabel(labels string) (string, plumbing.Hash, error) {
+	candidates := utils.SplitLabels(labels)
+	if len(candidates) == 0 {
+		candidates = []string{s.defaultedBranch("")}
+	}
+
+	var lastErr error
+	for _, label := range candidates {
+		hash, err := s.resolveLabel(label)
+		if err == nil {
+			return label, hash, nil
+		}
+		if !isRequestError(err) {
+			return "", plumbing.ZeroHash, err
+		}
+
+		log.Debug().Msgf("Label [%s] not found: %v", label, err)
+		lastErr = err
+	}
+
+	if len(candidates) > 1 {
+		return "", plumbing.ZeroHash, fmt.Errorf("%w in [%s]", errUnknownLabel, labels)
+	}
+	return "", plumbing.ZeroHash, lastErr
+}
+
+// Resolves as a branch (as last fetched, else local), then a tag, then a full or abbreviated commit hash
+func (s *Backend) resolveLabel(label string) (plumbing.Hash, error) {
+	repo := s.currentRepo()
+	if repo == nil {
+		return plumbing.ZeroHash, errRepoUnavailable
+	}
+
+	s.commitsLock.Lock()
+	defer s.commitsLock.Unlock()
+
+	for _, name := range []plumbing.ReferenceName{remoteBranchRef(label), branchRef(label)} {
+		if ref, err := repo.Reference(name, true); err == nil {
+			return ref.Hash(), nil
+		}
+	}
+
+	if ref, err := repo.Reference(plumbing.NewTagReferenceName(label), true); err == nil {
+		// Annotated tags point at a tag object rather than the commit
+		if tag, e := repo.TagObject(ref.Hash()); e == nil {
+			commit, e := tag.Commit()
+			if e != nil {
+				return plumbing.ZeroHash, e
+			}
+			return commit.Hash, nil
+		}
+		return ref.Hash(), nil
+	}
+
+	if isHashPrefix(label) {
+		if resolved, err := repo.ResolveRevision(plumbing.Revision(label)); err == nil {
+			return *resolved, nil
+		}
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("%w [%s]", errUnknownLabel, label)
+}
+
+func isHashPrefix(label string) bool {
+	if len(label) < minHashPrefix || len(label) > hash.HexSize {
+		return false
+	}
+	for _, c := range label {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
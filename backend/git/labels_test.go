@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHashPrefix(t *testing.T) {
+	assert.True(t, isHashPrefix("abc1"))
+	assert.True(t, isHashPrefix("0123456789abcdef0123456789ABCDEF01234567"))
+	assert.False(t, isHashPrefix("abc"))
+	assert.False(t, isHashPrefix("feature"))
+	assert.False(t, isHashPrefix("0123456789abcdef0123456789abcdef012345678"))
+}
+
+func TestGetCurrentStateResolvesLabels(t *testing.T) {
+	ctxt := context.Background()
+
+	remoteDir := t.TempDir()
+	repo, first := _newRepoAt(t, remoteDir, time.Now().Add(-time.Hour))
+
+	_, err := repo.CreateTag("v1", plumbing.NewHash(first), nil)
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1-annotated", plumbing.NewHash(first), &goGit.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "A", Email: "a@b.com", When: time.Now()},
+		Message: "v1",
+	})
+	require.NoError(t, err)
+
+	second := _commitFile(t, remoteDir, repo, "application.yaml", "a: c")
+
+	tests := []struct {
+		name        string
+		labels      string
+		wantLabel   string
+		wantVersion string
+		wantErr     string
+	}{
+		{name: "default branch", labels: "", wantLabel: "master", wantVersion: second},
+		{name: "branch", labels: "master", wantLabel: "master", wantVersion: second},
+		{name: "tag", labels: "v1", wantLabel: "v1", wantVersion: first},
+		{name: "annotated tag", labels: "v1-annotated", wantLabel: "v1-annotated", wantVersion: first},
+		{name: "full hash", labels: first, wantLabel: first, wantVersion: first},
+		{name: "abbreviated hash", labels: first[:7], wantLabel: first[:7], wantVersion: first},
+		{name: "fallback chain", labels: "feature-x,v1,master", wantLabel: "v1", wantVersion: first},
+		{name: "unknown", labels: "feature-x", wantErr: "no branch, tag or commit matches label [feature-x]"},
+		{name: "unknown chain", labels: "feature-x,feature-y", wantErr: "no branch, tag or commit matches label in [feature-x,feature-y]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Backend{Config: config.GitConfig{Uri: remoteDir, Basedir: t.TempDir()}}
+
+			state, err := b.GetCurrentState(ctxt, nil, nil, tt.labels, true)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLabel, state.Label)
+			assert.Equal(t, tt.wantVersion, state.Version)
+
+			// ... and again, locally
+			state, err = b.GetCurrentState(ctxt, nil, nil, tt.labels, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, state.Version)
+		})
+	}
+}
+
+func TestGetCurrentStateServesLabelsConcurrently(t *testing.T) {
+	ctxt := context.Background()
+
+	remoteDir := t.TempDir()
+	repo, first := _newRepoAt(t, remoteDir, time.Now().Add(-time.Hour))
+	_, err := repo.CreateTag("v1", plumbing.NewHash(first), nil)
+	require.NoError(t, err)
+
+	b := &Backend{Config: config.GitConfig{Uri: remoteDir, Basedir: t.TempDir(), RefreshRateMillis: 60_000}}
+
+	state, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, first, state.Version)
+
+	// A new commit is only seen once fetched
+	second := _commitFile(t, remoteDir, repo, "application.yaml", "a: c")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(label string) {
+			defer wg.Done()
+			st, e := b.GetCurrentState(ctxt, nil, nil, label, true)
+			assert.NoError(t, e)
+			assert.Equal(t, first, st.Version)
+		}([]string{"v1", "master", first[:8]}[i%3])
+	}
+	wg.Wait()
+
+	require.NoError(t, b.connect(ctxt, false, true))
+
+	state, err = b.GetCurrentState(ctxt, nil, nil, "master", true)
+	require.NoError(t, err)
+	assert.Equal(t, second, state.Version)
+
+	state, err = b.GetCurrentState(ctxt, nil, nil, "v1", true)
+	require.NoError(t, err)
+	assert.Equal(t, first, state.Version)
+
+	// Nothing is checked out
+	_, err = os.Stat(filepath.Join(b.Config.Basedir, "application.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func _commitFile(t *testing.T, dir string, repo *goGit.Repository, name string, contents string) string {
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	_, err = wt.Add(name)
+	require.NoError(t, err)
+
+	hash, err := wt.Commit("", &goGit.CommitOptions{Author: &object.Signature{Name: "A", Email: "a@b.com", When: time.Now()}})
+	require.NoError(t, err)
+	return hash.String()
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+const defaultMaxInMemoryBytes = 256 << 20
+
+var errTooLarge = errors.New("in-memory repository too large")
+
+func (s *Backend) openRepo() (*goGit.Repository, error) {
+	if !s.Config.InMemory {
+		return goGit.PlainOpen(s.Config.Basedir)
+	}
+	repo := s.currentRepo()
+	if repo == nil || s.memStorage == nil {
+		return nil, goGit.ErrRepositoryNotExists
+	}
+	return repo, nil
+}
+
+// Nothing is ever checked out, so an in-memory clone needs no worktree filesystem
+func (s *Backend) cloneRepo(ctxt context.Context, cloneOpts *goGit.CloneOptions) (*goGit.Repository, error) {
+	if !s.Config.InMemory {
+		return goGit.PlainCloneContext(ctxt, s.Config.Basedir, true, cloneOpts)
+	}
+
+	storage := memory.NewStorage()
+	repo, err := goGit.CloneContext(ctxt, s.limited(storage), nil, cloneOpts)
+	if err != nil {
+		return nil, s.checkTooLarge(err)
+	}
+
+	s.memStorage = storage
+	return repo, nil
+}
+
+// Requests read the in-memory repository without go-git locking it, even after we return their state, so fetches go
+// into a copy that replaces it once complete. Objects never change, so only the maps holding them are copied.
+func (s *Backend) fetchTarget(repo *goGit.Repository) (*goGit.Repository, *memory.Storage, error) {
+	if !s.Config.InMemory {
+		return repo, nil, nil
+	}
+
+	storage := copyStorage(s.memStorage)
+	copied, err := goGit.Open(s.limited(storage), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return copied, storage, nil
+}
+
+func copyStorage(from *memory.Storage) *memory.Storage {
+	to := memory.NewStorage()
+	to.ConfigStorage = from.ConfigStorage
+	to.IndexStorage = from.IndexStorage
+	to.ShallowStorage = slices.Clone(from.ShallowStorage)
+
+	maps.Copy(to.Objects, from.Objects)
+	maps.Copy(to.Commits, from.Commits)
+	maps.Copy(to.Trees, from.Trees)
+	maps.Copy(to.Blobs, from.Blobs)
+	maps.Copy(to.Tags, from.Tags)
+	maps.Copy(to.ReferenceStorage, from.ReferenceStorage)
+	maps.Copy(to.ModuleStorage, from.ModuleStorage)
+	return to
+}
+
+// limitedStorage fails as soon as the objects it holds pass `limit` bytes, so that an oversized clone or fetch stops
+// while it's being transferred, rather than once it's all in memory
+type limitedStorage struct {
+	*memory.Storage
+	limit int64
+	size  int64
+}
+
+func (s *Backend) limited(storage *memory.Storage) *limitedStorage {
+	limit := s.Config.MaxInMemoryBytes
+	if limit <= 0 {
+		limit = defaultMaxInMemoryBytes
+	}
+
+	var size int64
+	for _, obj := range storage.Objects {
+		size += obj.Size()
+	}
+	return &limitedStorage{Storage: storage, limit: limit, size: size}
+}
+
+func (l *limitedStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	if _, found := l.Objects[obj.Hash()]; !found {
+		l.size += obj.Size()
+	}
+	if l.size > l.limit {
+		return plumbing.ZeroHash, fmt.Errorf("%w: more than maxInMemoryBytes of %d", errTooLarge, l.limit)
+	}
+	return l.Storage.SetEncodedObject(obj)
+}
+
+// The repository only ever grows, so once too large we stop cloning or fetching it - each attempt would transfer up to
+// the limit again - until the configuration changes, and with it the backend
+func (s *Backend) checkTooLarge(err error) error {
+	if errors.Is(err, errTooLarge) {
+		s.tooLarge = fmt.Errorf("%w, not fetched again until the configuration changes", err)
+		return s.tooLarge
+	}
+	return err
+}
+
+func (s *Backend) discardMemoryRepo() {
+	s.memStorage = nil
+	s.setRepo(nil)
+}
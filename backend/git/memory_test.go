@@ -0,0 +1,135 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryClone(t *testing.T) {
+	ctxt := context.Background()
+
+	remoteDir := t.TempDir()
+	repo, first := _newRepoAt(t, remoteDir, time.Now().Add(-time.Hour))
+
+	t.Run("clone and fetch", func(t *testing.T) {
+		b := &Backend{Config: config.GitConfig{Uri: remoteDir, InMemory: true}}
+
+		state, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+		assert.Equal(t, first, state.Version)
+		assert.NotNil(t, b.memStorage)
+
+		second := _commitFile(t, remoteDir, repo, "accounts.yaml", "x: y")
+
+		state, err = b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+		assert.Equal(t, second, state.Version)
+
+		names := map[string]bool{}
+		require.NoError(t, state.Files.ForEach(func(f backend.File) error {
+			names[f.Name()] = true
+			return nil
+		}))
+		assert.Equal(t, map[string]bool{"application.yaml": true, "accounts.yaml": true}, names)
+
+		state, err = b.GetCurrentState(ctxt, nil, nil, first[:7], false)
+		require.NoError(t, err)
+		assert.Equal(t, first, state.Version)
+	})
+
+	t.Run("size guard on clone", func(t *testing.T) {
+		b := &Backend{Config: config.GitConfig{Uri: remoteDir, InMemory: true, MaxInMemoryBytes: 10}}
+
+		_, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.ErrorContains(t, err, "more than maxInMemoryBytes of 10")
+		assert.Nil(t, b.Repo)
+		assert.Nil(t, b.memStorage)
+
+		// Would now fit, but isn't cloned again until the configuration changes
+		b.Config.MaxInMemoryBytes = 0
+		_, err = b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.ErrorContains(t, err, "not fetched again until the configuration changes")
+		assert.Nil(t, b.Repo)
+	})
+
+	t.Run("size guard on fetch", func(t *testing.T) {
+		fetchDir := t.TempDir()
+		fetchRepo, fetchFirst := _newRepoAt(t, fetchDir, time.Now().Add(-time.Hour))
+
+		b := &Backend{Config: config.GitConfig{Uri: fetchDir, InMemory: true}}
+		_, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+
+		var size int64
+		for _, obj := range b.memStorage.Objects {
+			size += obj.Size()
+		}
+		b.Config.MaxInMemoryBytes = size + 10
+
+		_commitFile(t, fetchDir, fetchRepo, "large.yaml", strings.Repeat("x: y\n", 100))
+
+		_, err = b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.ErrorContains(t, err, fmt.Sprintf("more than maxInMemoryBytes of %d", size+10))
+
+		// What we already had is untouched
+		state, err := b.GetCurrentState(ctxt, nil, nil, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, fetchFirst, state.Version)
+
+		b.Config.MaxInMemoryBytes = 0
+		_, err = b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.ErrorContains(t, err, "not fetched again until the configuration changes")
+	})
+
+	t.Run("concurrent refresh and reads", func(t *testing.T) {
+		b := &Backend{Config: config.GitConfig{Uri: remoteDir, InMemory: true}}
+		_, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					state, e := b.GetCurrentState(ctxt, nil, nil, "", i%2 == 0)
+					if !assert.NoError(t, e) {
+						return
+					}
+					assert.NoError(t, state.Files.ForEach(func(f backend.File) error {
+						_, e := f.ToMap()
+						return e
+					}))
+				}
+			}()
+		}
+
+		var last string
+		for i := 0; i < 5; i++ {
+			last = _commitFile(t, remoteDir, repo, fmt.Sprintf("app%d.yaml", i), "x: y")
+			time.Sleep(20 * time.Millisecond)
+		}
+		close(done)
+		wg.Wait()
+
+		state, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+		assert.Equal(t, last, state.Version)
+	})
+}
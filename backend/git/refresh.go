@@ -0,0 +1,79 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Refresh fetches immediately from whichever of our repositories match `locations`, unless labels are disabled
+// and `branch` isn't the one we serve
+func (s *Backend) Refresh(ctxt context.Context, locations []string, branch string) ([]string, error) {
+	candidates := []*Backend{s}
+	for _, each := range s.repos {
+		candidates = append(candidates, each.backend)
+	}
+
+	var refreshed []string
+	var errs []error
+
+	for _, each := range candidates {
+		if !each.servesLocation(locations) || !each.servesBranch(branch) {
+			continue
+		}
+
+		if e := each.connect(ctxt, false, true); e != nil {
+			errs = append(errs, fmt.Errorf("refresh of %s failed: %w", each.Config.Uri, e))
+			continue
+		}
+
+		log.Info().Msgf("Refreshed %s on notification", each.Config.Uri)
+		refreshed = append(refreshed, each.Config.Uri)
+	}
+
+	return refreshed, errors.Join(errs...)
+}
+
+func (s *Backend) servesLocation(locations []string) bool {
+	if len(locations) == 0 {
+		return true
+	}
+
+	ours := normaliseRepoUri(s.Config.Uri)
+	for _, each := range locations {
+		if normaliseRepoUri(each) == ours {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Backend) servesBranch(branch string) bool {
+	return branch == "" || !s.Config.DisableLabels || branch == s.defaultedBranch("")
+}
+
+// Reduces `https://user@Host:443/org/repo.git`, `ssh://git@host/org/repo` and `git@host:org/repo.git` alike to
+// `host/org/repo`, so that any of the clone URLs in a webhook payload can match
+func normaliseRepoUri(uri string) string {
+	uri = strings.TrimSpace(uri)
+
+	if strings.Contains(uri, "://") {
+		if u, err := url.Parse(uri); err == nil {
+			if u.Scheme == "file" {
+				uri = u.Path
+			} else {
+				uri = u.Hostname() + "/" + strings.TrimPrefix(u.Path, "/")
+			}
+		}
+	} else if at := strings.Index(uri, "@"); at >= 0 {
+		if host, repoPath, found := strings.Cut(uri[at+1:], ":"); found {
+			uri = host + "/" + strings.TrimPrefix(repoPath, "/")
+		}
+	}
+
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(uri, "/"), ".git"))
+}
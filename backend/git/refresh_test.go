@@ -0,0 +1,76 @@
+package git
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormaliseRepoUri(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "https://github.com/Org/config.git", want: "github.com/org/config"},
+		{uri: "https://user@github.com:443/Org/config/", want: "github.com/org/config"},
+		{uri: "git@github.com:Org/config.git", want: "github.com/org/config"},
+		{uri: "ssh://git@github.com/Org/config.git", want: "github.com/org/config"},
+		{uri: "ssh://git@bitbucket.example.com:7999/org/config.git", want: "bitbucket.example.com/org/config"},
+		{uri: "file:///srv/git/config.git", want: "/srv/git/config"},
+		{uri: "/srv/git/config", want: "/srv/git/config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			assert.Equal(t, tt.want, normaliseRepoUri(tt.uri))
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctxt := context.Background()
+
+	remoteDir := t.TempDir()
+	repo, first := _newRepoAt(t, remoteDir, time.Now().Add(-time.Hour))
+
+	otherDir := t.TempDir()
+	_, _ = _newRepoAt(t, otherDir, time.Now())
+
+	b := &Backend{}
+	require.NoError(t, b.Init(ctxt, config.ApplicationConfiguration{Git: config.GitConfig{
+		Uri:          remoteDir,
+		Basedir:      t.TempDir(),
+		CloneOnStart: true,
+		Repos: []config.GitRepoConfig{
+			{Name: "other", Pattern: []string{"other"}, Uri: otherDir, Basedir: t.TempDir()},
+		},
+	}}))
+	defer b.Close()
+
+	// Only reads what was last fetched
+	second := _commitFile(t, remoteDir, repo, "application.yaml", "a: c")
+
+	state, err := b.GetCurrentState(ctxt, nil, nil, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, first, state.Version)
+
+	refreshed, err := b.Refresh(ctxt, []string{"file://" + otherDir}, "master")
+	require.NoError(t, err)
+	assert.Equal(t, []string{otherDir}, refreshed)
+
+	refreshed, err = b.Refresh(ctxt, []string{"https://example.com/nope.git", remoteDir + ".git"}, "master")
+	require.NoError(t, err)
+	assert.Equal(t, []string{remoteDir}, refreshed)
+
+	state, err = b.GetCurrentState(ctxt, nil, nil, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, second, state.Version)
+
+	b.Config.DisableLabels = true
+	refreshed, err = b.Refresh(ctxt, nil, "feature")
+	require.NoError(t, err)
+	assert.Equal(t, []string{otherDir}, refreshed)
+}
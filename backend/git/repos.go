@@ -0,0 +1,103 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultProfile = "default" // matched against when no profiles were requested
+
+// A further repository, serving the applications / profiles matching its patterns
+type routedRepo struct {
+	name     string
+	patterns []string
+	backend  *Backend
+}
+
+func (s *Backend) initRoutedRepos(ctxt context.Context) error {
+	names := map[string]bool{}
+
+	for _, each := range s.Config.Repos {
+		if each.Name == "" || each.Uri == "" {
+			return errors.New("git repos require both a name and a uri")
+		}
+		if names[each.Name] {
+			return fmt.Errorf("duplicate git repo name [%s]", each.Name)
+		}
+		names[each.Name] = true
+
+		patterns, err := normalisePatterns(each.Pattern)
+		if err != nil {
+			return fmt.Errorf("git repo [%s]: %w", each.Name, err)
+		}
+
+		child := &Backend{EnableTrace: s.EnableTrace, cacheConfig: s.cacheConfig}
+		if e := child.initRepo(ctxt, s.Config.ForRepo(each)); e != nil {
+			return fmt.Errorf("git repo [%s]: %w", each.Name, e)
+		}
+
+		log.Info().Msgf("Git repo [%s] serves %v from %s", each.Name, patterns, each.Uri)
+
+		s.repos = append(s.repos, routedRepo{name: each.Name, patterns: patterns, backend: child})
+	}
+	return nil
+}
+
+// Patterns are `{application}/{profile}`, where a missing profile means any
+func normalisePatterns(patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no pattern specified")
+	}
+
+	var normalised []string
+	for _, each := range patterns {
+		each = strings.TrimSpace(each)
+		if each == "" {
+			continue
+		}
+		if !strings.Contains(each, "/") {
+			each += "/*"
+		}
+		if _, err := path.Match(each, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern [%s]: %w", each, err)
+		}
+		normalised = append(normalised, each)
+	}
+
+	if len(normalised) == 0 {
+		return nil, errors.New("no pattern specified")
+	}
+	return normalised, nil
+}
+
+// The first repository, in configured order, with a pattern matching any requested application / profile, else nil
+func (s *Backend) routeTo(applications []string, profiles []string) *Backend {
+	if len(profiles) == 0 {
+		profiles = []string{defaultProfile}
+	}
+
+	for _, each := range s.repos {
+		if each.matches(applications, profiles) {
+			return each.backend
+		}
+	}
+	return nil
+}
+
+func (r routedRepo) matches(applications []string, profiles []string) bool {
+	for _, pattern := range r.patterns {
+		for _, app := range applications {
+			for _, profile := range profiles {
+				if matched, _ := path.Match(pattern, app+"/"+profile); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package git
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalisePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  string
+	}{
+		{name: "application only", patterns: []string{"payments*"}, want: []string{"payments*/*"}},
+		{name: "application and profile", patterns: []string{" ledger/prod* ", ""}, want: []string{"ledger/prod*"}},
+		{name: "none", patterns: []string{" "}, wantErr: "no pattern specified"},
+		{name: "invalid", patterns: []string{"pay[ments"}, wantErr: "invalid pattern [pay[ments/*]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalisePatterns(tt.patterns)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRouteTo(t *testing.T) {
+	payments := &Backend{}
+	ledgerProd := &Backend{}
+
+	b := &Backend{repos: []routedRepo{
+		{name: "payments", patterns: []string{"payments*/*"}, backend: payments},
+		{name: "ledger", patterns: []string{"ledger/prod*", "ledger/default"}, backend: ledgerProd},
+	}}
+
+	tests := []struct {
+		name         string
+		applications []string
+		profiles     []string
+		want         *Backend
+	}{
+		{name: "application match", applications: []string{"payments-api"}, profiles: []string{"dev"}, want: payments},
+		{name: "any application", applications: []string{"other", "payments"}, profiles: []string{"dev"}, want: payments},
+		{name: "profile match", applications: []string{"ledger"}, profiles: []string{"dev", "production"}, want: ledgerProd},
+		{name: "default profile", applications: []string{"ledger"}, want: ledgerProd},
+		{name: "profile mismatch", applications: []string{"ledger"}, profiles: []string{"dev"}, want: nil},
+		{name: "no match", applications: []string{"accounts"}, profiles: []string{"prod"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Same(t, tt.want, b.routeTo(tt.applications, tt.profiles))
+		})
+	}
+}
+
+func TestGetCurrentStateRoutesToRepos(t *testing.T) {
+	ctxt := context.Background()
+
+	defaultDir := t.TempDir()
+	_, defaultHash := _newRepoAt(t, defaultDir, time.Now())
+
+	paymentsDir := t.TempDir()
+	_, paymentsHash := _newRepoAt(t, paymentsDir, time.Now().Add(-time.Hour))
+
+	b := &Backend{}
+	err := b.Init(ctxt, config.ApplicationConfiguration{Git: config.GitConfig{
+		Uri:     defaultDir,
+		Basedir: t.TempDir(),
+		Repos: []config.GitRepoConfig{
+			{Name: "payments", Pattern: []string{"payments*"}, Uri: paymentsDir, Basedir: t.TempDir()},
+		},
+	}})
+	require.NoError(t, err)
+	defer b.Close()
+
+	state, err := b.GetCurrentState(ctxt, []string{"payments-api"}, []string{"prod"}, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, paymentsHash, state.Version)
+
+	state, err = b.GetCurrentState(ctxt, []string{"accounts"}, []string{"prod"}, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, defaultHash, state.Version)
+
+	h, err := b.Health(ctxt)
+	require.NoError(t, err)
+	assert.Equal(t, defaultHash, h.Version)
+	assert.Equal(t, paymentsHash, h.Repositories["payments"].Version)
+}
+
+func TestInitRejectsInvalidRepos(t *testing.T) {
+	tests := []struct {
+		name    string
+		repos   []config.GitRepoConfig
+		wantErr string
+	}{
+		{name: "no uri", repos: []config.GitRepoConfig{{Name: "a", Pattern: []string{"a"}}}, wantErr: "require both a name and a uri"},
+		{name: "no pattern", repos: []config.GitRepoConfig{{Name: "a", Uri: "/a"}}, wantErr: "git repo [a]: no pattern specified"},
+		{
+			name:    "duplicate",
+			repos:   []config.GitRepoConfig{{Name: "a", Uri: "/a", Pattern: []string{"a"}}, {Name: "a", Uri: "/b", Pattern: []string{"b"}}},
+			wantErr: "duplicate git repo name [a]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Backend{}
+			err := b.Init(context.Background(), config.ApplicationConfiguration{Git: config.GitConfig{Repos: tt.repos}})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
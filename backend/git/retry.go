@@ -0,0 +1,152 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrCircuitOpen = errors.New("git remote circuit breaker is open, not retrying yet")
+
+// errSuperseded ends retries once another connect has refreshed the repository while this one was backing off
+var errSuperseded = errors.New("refreshed meanwhile")
+
+// Run a clone, pull or fetch against the remote with a deadline per attempt, jittered exponential backoff between
+// attempts, and a circuit breaker across all of them. Called holding `connectLock`, which is released while backing
+// off, so that other requests needn't wait for the retries.
+func (s *Backend) withRetries(ctxt context.Context, opName string, op func(ctx context.Context) error) error {
+	policy := s.Config.Retry.Validate()
+	started := time.Now()
+
+	if !policy.DisableCircuitBreaker {
+		if err := s.breaker.allow(time.Duration(policy.CircuitBreakerResetMillis) * time.Millisecond); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = s.attempt(ctxt, op)
+		if err == nil || !isRetryable(err) || attempt >= policy.MaxAttempts {
+			break
+		}
+
+		wait := backoff(attempt, policy.InitialBackoffMillis, policy.MaxBackoffMillis)
+		log.Warn().Err(err).Msgf("Git %s failed (attempt %d of %d), retrying in %v", opName, attempt, policy.MaxAttempts, wait)
+
+		s.connectLock.Unlock()
+		select {
+		case <-ctxt.Done():
+			s.connectLock.Lock()
+			return ctxt.Err()
+		case <-time.After(wait):
+		}
+		s.connectLock.Lock()
+
+		if s.refreshedSince(started) {
+			return errSuperseded
+		}
+	}
+
+	// A caller going away says nothing about the remote
+	if !policy.DisableCircuitBreaker && !isRequestError(err) && !errors.Is(err, context.Canceled) {
+		s.breaker.record(err, policy.CircuitBreakerThreshold)
+	}
+
+	return err
+}
+
+func (s *Backend) attempt(ctxt context.Context, op func(ctx context.Context) error) error {
+	if s.Config.TimeoutMillis <= 0 {
+		return op(ctxt)
+	}
+
+	ctx, cancel := context.WithTimeout(ctxt, time.Duration(s.Config.TimeoutMillis)*time.Millisecond)
+	defer cancel()
+	return op(ctx)
+}
+
+// "Full jitter": anywhere between zero and the exponentially growing cap
+func backoff(attempt int, initialMillis int64, maxMillis int64) time.Duration {
+	capMillis := initialMillis << min(attempt-1, 30)
+	if capMillis <= 0 || capMillis > maxMillis {
+		capMillis = maxMillis
+	}
+	return time.Duration(rand.Int64N(capMillis)+1) * time.Millisecond
+}
+
+func isRetryable(err error) bool {
+	switch {
+	case errors.Is(err, goGit.NoErrAlreadyUpToDate),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, errTooLarge),
+		errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod),
+		errors.Is(err, transport.ErrRepositoryNotFound),
+		errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return false
+	}
+	return !isRequestError(err)
+}
+
+// Errors caused by what was asked for, e.g. an unknown label, rather than by the remote itself
+func isRequestError(err error) bool {
+	var noMatchingRefSpec goGit.NoMatchingRefSpecError
+	return errors.Is(err, errUnknownLabel) ||
+		errors.Is(err, plumbing.ErrReferenceNotFound) ||
+		errors.Is(err, goGit.ErrBranchNotFound) ||
+		errors.As(err, &noMatchingRefSpec)
+}
+
+// Closed until `threshold` consecutive failures, then open until `reset` has passed, when one trial call is let through
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func (cb *circuitBreaker) allow(reset time.Duration) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.openedAt.IsZero() {
+		return nil
+	}
+
+	if time.Since(cb.openedAt) < reset {
+		return ErrCircuitOpen
+	}
+
+	cb.openedAt = time.Now() // half-open: hold everyone else back while this trial runs
+	return nil
+}
+
+func (cb *circuitBreaker) record(err error, threshold int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil || errors.Is(err, goGit.NoErrAlreadyUpToDate) {
+		if !cb.openedAt.IsZero() {
+			log.Info().Msg("Git remote circuit breaker closed")
+		}
+		cb.failures = 0
+		cb.openedAt = time.Time{}
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= threshold {
+		if cb.openedAt.IsZero() {
+			log.Error().Err(err).Msgf("Git remote circuit breaker opened after %d consecutive failures", cb.failures)
+		}
+		cb.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,209 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errFlaky = errors.New("connection reset by peer")
+
+func TestWithRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retry     config.GitRetryConfig
+		failures  []error
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "succeeds first time",
+			retry:     config.GitRetryConfig{InitialBackoffMillis: 1},
+			wantCalls: 1,
+		},
+		{
+			name:      "succeeds after retries",
+			retry:     config.GitRetryConfig{MaxAttempts: 3, InitialBackoffMillis: 1},
+			failures:  []error{errFlaky, errFlaky},
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after max attempts",
+			retry:     config.GitRetryConfig{MaxAttempts: 2, InitialBackoffMillis: 1},
+			failures:  []error{errFlaky, errFlaky, errFlaky},
+			wantErr:   errFlaky,
+			wantCalls: 2,
+		},
+		{
+			name:      "auth failures are not retried",
+			retry:     config.GitRetryConfig{MaxAttempts: 3, InitialBackoffMillis: 1},
+			failures:  []error{transport.ErrAuthenticationRequired},
+			wantErr:   transport.ErrAuthenticationRequired,
+			wantCalls: 1,
+		},
+		{
+			name:      "already up to date is not retried",
+			retry:     config.GitRetryConfig{MaxAttempts: 3, InitialBackoffMillis: 1},
+			failures:  []error{goGit.NoErrAlreadyUpToDate},
+			wantErr:   goGit.NoErrAlreadyUpToDate,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Backend{Config: config.GitConfig{Retry: tt.retry}}
+			b.connectLock.Lock() // as when connecting
+			defer b.connectLock.Unlock()
+
+			calls := 0
+			err := b.withRetries(context.Background(), "test", func(_ context.Context) error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestWithRetriesTimeout(t *testing.T) {
+	b := &Backend{Config: config.GitConfig{
+		TimeoutMillis: 20,
+		Retry:         config.GitRetryConfig{MaxAttempts: 1},
+	}}
+
+	err := b.withRetries(context.Background(), "test", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithRetriesReleasesLockWhileBackingOff(t *testing.T) {
+	b := &Backend{Config: config.GitConfig{Retry: config.GitRetryConfig{MaxAttempts: 5, InitialBackoffMillis: 200, MaxBackoffMillis: 200}}}
+	b.connectLock.Lock()
+
+	result := make(chan error)
+	go func() {
+		defer b.connectLock.Unlock()
+		result <- b.withRetries(context.Background(), "test", func(_ context.Context) error {
+			return errFlaky
+		})
+	}()
+
+	// Another request gets in between attempts, and refreshes
+	require.Eventually(t, b.connectLock.TryLock, 5*time.Second, time.Millisecond)
+	b.recordRefresh(nil)
+	b.connectLock.Unlock()
+
+	assert.ErrorIs(t, <-result, errSuperseded)
+}
+
+func TestCancellationIsNotAFailure(t *testing.T) {
+	b := &Backend{Config: config.GitConfig{Retry: config.GitRetryConfig{
+		MaxAttempts:               1,
+		CircuitBreakerThreshold:   1,
+		CircuitBreakerResetMillis: 60_000,
+	}}}
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := b.withRetries(ctxt, "test", func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	b.recordRefresh(err)
+
+	assert.NoError(t, b.breaker.allow(time.Minute))
+	assert.False(t, b.isStale())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &Backend{Config: config.GitConfig{Retry: config.GitRetryConfig{
+		MaxAttempts:               1,
+		CircuitBreakerThreshold:   2,
+		CircuitBreakerResetMillis: 50,
+	}}}
+
+	calls := 0
+	failing := func(_ context.Context) error {
+		calls++
+		return errFlaky
+	}
+
+	assert.Equal(t, errFlaky, b.withRetries(context.Background(), "test", failing))
+	assert.Equal(t, errFlaky, b.withRetries(context.Background(), "test", failing))
+
+	// Open: the remote isn't touched
+	assert.Equal(t, ErrCircuitOpen, b.withRetries(context.Background(), "test", failing))
+	assert.Equal(t, 2, calls)
+
+	// Half-open after the reset period: a successful trial closes it again
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.withRetries(context.Background(), "test", func(_ context.Context) error {
+		calls++
+		return nil
+	}))
+	assert.Equal(t, errFlaky, b.withRetries(context.Background(), "test", failing))
+	assert.Equal(t, 4, calls)
+}
+
+func TestCloneOverFileProtocol(t *testing.T) {
+	ctxt := context.Background()
+
+	workDir := t.TempDir()
+	_, hash := _newRepoAt(t, workDir, time.Now())
+
+	bareDir := filepath.Join(t.TempDir(), "config.git")
+	_, err := goGit.PlainClone(bareDir, true, &goGit.CloneOptions{URL: workDir})
+	require.NoError(t, err)
+
+	t.Run("clone and pull", func(t *testing.T) {
+		b := &Backend{Config: config.GitConfig{
+			Uri:           "file://" + bareDir,
+			Basedir:       t.TempDir(),
+			TimeoutMillis: 10_000,
+		}}
+
+		state, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		require.NoError(t, err)
+		assert.Equal(t, hash, state.Version)
+
+		state, err = b.GetCurrentState(ctxt, nil, nil, "", true) // now a pull
+		require.NoError(t, err)
+		assert.Equal(t, hash, state.Version)
+	})
+
+	t.Run("unreachable remote opens the breaker", func(t *testing.T) {
+		b := &Backend{Config: config.GitConfig{
+			Uri:     "file://" + filepath.Join(t.TempDir(), "missing.git"),
+			Basedir: t.TempDir(),
+			Retry: config.GitRetryConfig{
+				MaxAttempts:             2,
+				InitialBackoffMillis:    1,
+				CircuitBreakerThreshold: 1,
+			},
+		}}
+
+		_, err := b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrCircuitOpen, err)
+
+		_, err = b.GetCurrentState(ctxt, nil, nil, "", true)
+		assert.Equal(t, ErrCircuitOpen, err)
+	})
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	applicationPlaceholder = "{application}"
+	profilePlaceholder     = "{profile}"
+	labelPlaceholder       = "{label}"
+)
+
+// Expand each `{application}`, `{profile}` and `{label}` for the request, preserving the configured order. Patterns
+// needing an application or profile that wasn't requested are dropped. Requested values are escaped, so only ever
+// match themselves, e.g. an application of `*` can't reach every other application's directory.
+func expandSearchPaths(patterns []string, applications []string, profiles []string, label string) []string {
+	var expanded []string
+	seen := map[string]bool{}
+
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.TrimPrefix(strings.TrimSpace(pattern), "./"), "/")
+		if pattern == "" {
+			continue
+		}
+
+		candidates := []string{strings.ReplaceAll(pattern, labelPlaceholder, escapeMeta(label))}
+		candidates = substitute(candidates, applicationPlaceholder, applications)
+		candidates = substitute(candidates, profilePlaceholder, profiles)
+
+		for _, each := range candidates {
+			if !seen[each] {
+				seen[each] = true
+				expanded = append(expanded, each)
+			}
+		}
+	}
+
+	return expanded
+}
+
+func substitute(candidates []string, placeholder string, values []string) []string {
+	var result []string
+	for _, each := range candidates {
+		if !strings.Contains(each, placeholder) {
+			result = append(result, each)
+			continue
+		}
+		for _, v := range values {
+			result = append(result, strings.ReplaceAll(each, placeholder, escapeMeta(v)))
+		}
+	}
+	return result
+}
+
+var metaEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapeMeta quotes whatever `path.Match` would otherwise treat as a pattern
+func escapeMeta(value string) string {
+	return metaEscaper.Replace(value)
+}
+
+// Rank 0 is the root, then the last (least specific) search path through to the first
+func searchRank(searchPaths []string, dir string) (int, bool) {
+	if dir == "." {
+		return 0, true
+	}
+
+	for i, pattern := range searchPaths {
+		if matched, _ := path.Match(pattern, dir); matched {
+			return len(searchPaths) - i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandSearchPaths(t *testing.T) {
+	tests := []struct {
+		name         string
+		patterns     []string
+		applications []string
+		profiles     []string
+		want         []string
+	}{
+		{
+			name:     "none",
+			patterns: nil,
+			want:     nil,
+		},
+		{
+			name:         "placeholders",
+			patterns:     []string{"{application}", "shared/*", "/config/{profile}/", "./{label}"},
+			applications: []string{"accounts", "security"},
+			profiles:     []string{"production", "base"},
+			want:         []string{"accounts", "security", "shared/*", "config/production", "config/base", "main"},
+		},
+		{
+			name:         "combined",
+			patterns:     []string{"{application}/{profile}", "{application}"},
+			applications: []string{"accounts", "security"},
+			profiles:     []string{"production"},
+			want:         []string{"accounts/production", "security/production", "accounts", "security"},
+		},
+		{
+			name:         "metacharacters requested",
+			patterns:     []string{"{application}", "config/{profile}"},
+			applications: []string{"*", "acc[a-z]*"},
+			profiles:     []string{"prod?", `back\slash`},
+			want:         []string{`\*`, `acc\[a-z\]\*`, `config/prod\?`, `config/back\\slash`},
+		},
+		{
+			name:         "no profiles requested",
+			patterns:     []string{"config/{profile}", "{application}", "accounts", ""},
+			applications: []string{"accounts"},
+			want:         []string{"accounts"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandSearchPaths(tt.patterns, tt.applications, tt.profiles, "main"))
+		})
+	}
+}
+
+func TestSearchRankOfRequestedMetacharacters(t *testing.T) {
+	searchPaths := expandSearchPaths([]string{"{application}", "{label}"}, []string{"*", "acc[a-z]*"}, nil, "r?")
+
+	for _, dir := range []string{"*", "acc[a-z]*", "r?"} {
+		_, ok := searchRank(searchPaths, dir)
+		assert.True(t, ok, dir)
+	}
+	for _, dir := range []string{"accounts", "security", "rc"} {
+		_, ok := searchRank(searchPaths, dir)
+		assert.False(t, ok, dir)
+	}
+}
+
+func TestSearchRank(t *testing.T) {
+	searchPaths := []string{"accounts", "shared/*"}
+
+	tests := []struct {
+		dir      string
+		wantRank int
+		wantOk   bool
+	}{
+		{dir: ".", wantRank: 0, wantOk: true},
+		{dir: "shared/payments", wantRank: 1, wantOk: true},
+		{dir: "accounts", wantRank: 2, wantOk: true},
+		{dir: "accounts/nested", wantOk: false},
+		{dir: "security", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			rank, ok := searchRank(searchPaths, tt.dir)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantRank, rank)
+		})
+	}
+}
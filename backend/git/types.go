@@ -1,34 +1,76 @@
 package git
 
 import (
+	"codnect.io/chrono"
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/cache"
 	"github.com/GlintPay/gccs/config"
 	"github.com/GlintPay/gccs/filetypes"
 	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"sync"
+	"time"
 )
 
 type Backend struct {
 	Config      config.GitConfig
-	Repo        *goGit.Repository
-	PublicKeys  *ssh.PublicKeys
+	Repo        *goGit.Repository // guarded by repoLock once serving
+	Auth        transport.AuthMethod
 	EnableTrace bool
 
 	commitsLock sync.RWMutex
+	connectLock sync.Mutex
+	repoLock    sync.RWMutex
+
+	memStorage *memory.Storage // only when `InMemory`
+	tooLarge   error           // once set, the in-memory repository is no longer cloned or fetched
+
+	scheduler chrono.TaskScheduler // only when polling
+
+	cacheConfig config.Cache
+	parsedOnce  sync.Once
+	parsed      *cache.LRU[plumbing.Hash, map[string]any] // by blob hash, created on first use
+
+	breaker circuitBreaker
+
+	healthLock     sync.RWMutex
+	lastRefresh    time.Time
+	lastRefreshErr error
+
+	repos []routedRepo
+
+	listeners []func(backend.Change)
 
 	YamlContext filetypes.YamlContext
 }
 
+func (s *Backend) Name() string {
+	return "git"
+}
+
 func (s *Backend) Order() int {
 	return s.Config.Order
 }
 
+var staleServes = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "gccs",
+	Subsystem: "git",
+	Name:      "stale_serves_total",
+	Help:      "Requests served from the last known good commit because the latest refresh failed",
+})
+
 type fileItrWrapper struct {
 	RepoUri     string
 	Files       *object.FileIter
 	Dir         string
+	SearchPaths []string
 	YamlContext filetypes.YamlContext
+	Parsed      *cache.LRU[plumbing.Hash, map[string]any]
 }
 
 type fileWrapper struct {
@@ -36,6 +78,7 @@ type fileWrapper struct {
 	File        *object.File
 	Dir         string
 	YamlContext filetypes.YamlContext
+	Parsed      *cache.LRU[plumbing.Hash, map[string]any]
 }
 
 type fileBlob struct {
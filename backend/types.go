@@ -4,24 +4,63 @@ import (
 	"context"
 	"github.com/GlintPay/gccs/config"
 	"io"
+	"time"
 )
 
 type Backends []Backend
 
 type Backend interface {
 	Ordering
+	Name() string
 	Init(ctxt context.Context, config config.ApplicationConfiguration) error
-	GetCurrentState(ctxt context.Context, branch string, refresh bool) (*State, error)
+	GetCurrentState(ctxt context.Context, applications []string, profiles []string, branch string, refresh bool) (*State, error)
+	Health(ctxt context.Context) (Health, error)
 	Close()
 }
 
+// Change describes new content picked up by a backend, e.g. a new commit on a branch
+type Change struct {
+	Backend         string
+	Location        string
+	Label           string
+	PreviousVersion string
+	Version         string
+	Files           []string // paths changed between the two versions
+}
+
+// Observable backends report each change as they pick it up
+type Observable interface {
+	Subscribe(listener func(Change))
+}
+
+// Refreshable backends can be told straight away that a remote has changed, e.g. by a push webhook
+type Refreshable interface {
+	// Refresh any of `locations` we serve (everything, if none) for the branch, returning what was refreshed
+	Refresh(ctxt context.Context, locations []string, branch string) ([]string, error)
+}
+
 type Ordering interface {
 	Order() int // lower is higher priority
 }
 
 type State struct {
 	Version string
+	Label   string // the label actually served, if the backend supports them
 	Files   FileIterator
+	Stale   bool // the backend could not refresh, so is serving older content
+}
+
+// Health is the detail a backend reports to readiness checks. An error returned alongside it means the backend cannot
+// currently be relied upon to serve configuration.
+type Health struct {
+	Location    string     `json:"location,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	CommitTime  *time.Time `json:"commitTime,omitempty"`
+	CommitAge   string     `json:"commitAge,omitempty"`
+	LastRefresh *time.Time `json:"lastRefresh,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+
+	Repositories map[string]Health `json:"repositories,omitempty"` // any further repositories, by name
 }
 
 type FileIterator interface {
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// LRU is a size-bounded cache, evicting the least recently used entry once full. Entries may also expire.
+type LRU[K comparable, V any] struct {
+	name       string
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[K]*list.Element
+	order   *list.List // most recently used at the front
+
+	now func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time // zero if never
+}
+
+var lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "gccs",
+	Subsystem: "cache",
+	Name:      "lookups_total",
+	Help:      "Cache lookups, by cache and whether they hit",
+}, []string{"cache", "result"})
+
+func New[K comparable, V any](name string, maxEntries int) *LRU[K, V] {
+	return &LRU[K, V]{
+		name:       name,
+		maxEntries: maxEntries,
+		entries:    map[K]*list.Element{},
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		found := elem.Value.(*entry[K, V])
+		if found.expires.IsZero() || c.now().Before(found.expires) {
+			c.order.MoveToFront(elem)
+			lookups.WithLabelValues(c.name, "hit").Inc()
+			return found.value, true
+		}
+		c.remove(elem)
+	}
+
+	lookups.WithLabelValues(c.name, "miss").Inc()
+
+	var none V
+	return none, false
+}
+
+// Put the value, to expire after `ttl` unless that is zero
+func (c *LRU[K, V]) Put(key K, value V, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = &entry[K, V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry[K, V]).key)
+}
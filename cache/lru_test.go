@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int]("test", 2)
+
+	c.Put("a", 1, 0)
+	c.Put("b", 2, 0)
+
+	_, _ = c.Get("a")
+	c.Put("c", 3, 0)
+
+	assert.Equal(t, 2, c.Len())
+
+	_, found := c.Get("b")
+	assert.False(t, found)
+
+	val, found := c.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, val)
+
+	val, found = c.Get("c")
+	assert.True(t, found)
+	assert.Equal(t, 3, val)
+}
+
+func TestReplaces(t *testing.T) {
+	c := New[string, int]("test", 2)
+
+	c.Put("a", 1, 0)
+	c.Put("a", 2, 0)
+
+	val, found := c.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 2, val)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestExpires(t *testing.T) {
+	now := time.Now()
+
+	c := New[string, int]("test", 2)
+	c.now = func() time.Time { return now }
+
+	c.Put("a", 1, time.Second)
+	c.Put("b", 2, 0)
+
+	now = now.Add(time.Second)
+
+	_, found := c.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 1, c.Len())
+
+	_, found = c.Get("b")
+	assert.True(t, found)
+}
@@ -0,0 +1,176 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/rs/zerolog/log"
+)
+
+// Reloader hands each TLS handshake the certificate and client CAs last loaded from disk, so that they can be
+// rotated without a restart
+type Reloader struct {
+	config  config.Tls
+	current atomic.Pointer[tls.Config]
+
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func New(cfg config.Tls) (*Reloader, error) {
+	r := &Reloader{config: cfg.Validate()}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig for an `http.Server`, always serving whatever was last loaded
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.current.Load().MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.current.Load().Certificates[0], nil
+		},
+	}
+}
+
+// Watch for changes every `interval`, keeping the current certificates if the new ones can't be loaded, e.g. while
+// only some of the files have been replaced
+func (r *Reloader) Watch(ctxt context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctxt.Done():
+				return
+			case <-ticker.C:
+				if e := r.reloadIfChanged(); e != nil {
+					log.Error().Err(e).Msg("Keeping existing TLS certificates")
+				}
+			}
+		}
+	}()
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCaFile != "" {
+		files = append(files, r.config.ClientCaFile)
+	}
+	return files
+}
+
+func (r *Reloader) reloadIfChanged() error {
+	for _, each := range r.files() {
+		info, err := os.Stat(each)
+		if err != nil {
+			return err
+		}
+		if r.stamps[each] != (fileStamp{modTime: info.ModTime(), size: info.Size()}) {
+			return r.reload()
+		}
+	}
+	return nil
+}
+
+func (r *Reloader) reload() error {
+	// Recorded even if invalid, so an error is only reported once per change
+	r.stamps = map[string]fileStamp{}
+	for _, each := range r.files() {
+		if info, err := os.Stat(each); err == nil {
+			r.stamps[each] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+
+	loaded, err := load(r.config)
+	if err != nil {
+		return err
+	}
+	r.current.Store(loaded)
+
+	leaf := loaded.Certificates[0].Leaf
+	log.Info().Msgf("Loaded TLS certificate for [%s], expiring %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+func load(cfg config.Tls) (*tls.Config, error) {
+	minVersion, err := parseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth, err := parseClientAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unloadable TLS certificate %s: %w", cfg.CertFile, err)
+	}
+
+	loaded := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		ClientAuth:   clientAuth,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if cfg.ClientCaFile != "" {
+		bs, e := os.ReadFile(cfg.ClientCaFile)
+		if e != nil {
+			return nil, e
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no CA certificates in %s", cfg.ClientCaFile)
+		}
+		loaded.ClientCAs = pool
+	}
+
+	return loaded, nil
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version [%s]", version)
+}
+
+func parseClientAuth(cfg config.Tls) (tls.ClientAuthType, error) {
+	if cfg.ClientCaFile == "" {
+		if cfg.ClientAuth != "" {
+			return 0, fmt.Errorf("TLS client auth [%s] requires a client CA", cfg.ClientAuth)
+		}
+		return tls.NoClientCert, nil
+	}
+
+	switch cfg.ClientAuth {
+	case "verifyIfGiven":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS client auth [%s]", cfg.ClientAuth)
+}
@@ -0,0 +1,220 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := _issue(t, nil, "ca")
+
+	cfg := config.Tls{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCaFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   "require",
+	}
+	_write(t, cfg.ClientCaFile, ca.certPem)
+	_writePair(t, cfg, _issue(t, &ca, "first"))
+
+	r, err := New(cfg)
+	require.NoError(t, err)
+
+	addr := _listen(t, r)
+	client := _issue(t, &ca, "client")
+
+	assert.Equal(t, "first", _handshake(t, addr, ca, &client.pair))
+
+	// With TLS 1.3, a missing client certificate is only reported on first read
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"})
+	if err == nil {
+		_, err = conn.Read(make([]byte, 2))
+		_ = conn.Close()
+	}
+	assert.ErrorContains(t, err, "certificate required")
+
+	// Half-written changes are ignored
+	_rewrite(t, cfg.CertFile, _issue(t, &ca, "second").certPem)
+	assert.Error(t, r.reloadIfChanged())
+	assert.Equal(t, "first", _handshake(t, addr, ca, &client.pair))
+
+	_writePair(t, cfg, _issue(t, &ca, "third"))
+	_touch(t, cfg.CertFile, cfg.KeyFile)
+	assert.NoError(t, r.reloadIfChanged())
+	assert.Equal(t, "third", _handshake(t, addr, ca, &client.pair))
+
+	assert.NoError(t, r.reloadIfChanged())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Tls{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	_writePair(t, cfg, _issue(t, nil, "server"))
+
+	tests := []struct {
+		name    string
+		change  func(c config.Tls) config.Tls
+		wantErr string
+	}{
+		{name: "version", change: func(c config.Tls) config.Tls { c.MinVersion = "1.1"; return c }, wantErr: "unsupported TLS version [1.1]"},
+		{name: "client auth without CA", change: func(c config.Tls) config.Tls { c.ClientAuth = "require"; return c }, wantErr: "TLS client auth [require] requires a client CA"},
+		{name: "client auth", change: func(c config.Tls) config.Tls {
+			c.ClientCaFile = c.CertFile
+			c.ClientAuth = "always"
+			return c
+		}, wantErr: "unsupported TLS client auth [always]"},
+		{name: "CA", change: func(c config.Tls) config.Tls { c.ClientCaFile = c.KeyFile; return c }, wantErr: "no CA certificates in " + cfg.KeyFile},
+		{name: "missing", change: func(c config.Tls) config.Tls { c.KeyFile = filepath.Join(dir, "missing"); return c }, wantErr: "unloadable TLS certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.change(cfg))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	r, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), r.TLSConfig().MinVersion)
+	assert.Equal(t, tls.NoClientCert, r.current.Load().ClientAuth)
+}
+
+type issued struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	keyPem  []byte
+	pair    tls.Certificate
+}
+
+func (i issued) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(i.cert)
+	return pool
+}
+
+// Self-signed if no CA is given
+func _issue(t *testing.T, ca *issued, name string) issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	parent, parentKey := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parent, parentKey = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	pair, err := tls.X509KeyPair(certPem, keyPem)
+	require.NoError(t, err)
+
+	return issued{cert: cert, key: key, certPem: certPem, keyPem: keyPem, pair: pair}
+}
+
+func _listen(t *testing.T, r *Reloader) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	require.NoError(t, err)
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = listener.Close()
+	})
+
+	go func() {
+		for ctxt.Err() == nil {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				if c.(*tls.Conn).Handshake() == nil {
+					_, _ = c.Write([]byte("ok"))
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// Returns the server certificate's common name
+func _handshake(t *testing.T, addr string, ca issued, clientCert *tls.Certificate) string {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		RootCAs:      ca.pool(),
+		ServerName:   "localhost",
+		Certificates: []tls.Certificate{*clientCert},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 2))
+	require.NoError(t, err)
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func _writePair(t *testing.T, cfg config.Tls, each issued) {
+	_write(t, cfg.CertFile, each.certPem)
+	_write(t, cfg.KeyFile, each.keyPem)
+}
+
+func _write(t *testing.T, file string, contents []byte) {
+	require.NoError(t, os.WriteFile(file, contents, 0600))
+}
+
+func _rewrite(t *testing.T, file string, contents []byte) {
+	_write(t, file, contents)
+	_touch(t, file)
+}
+
+// Moves the modification time on, in case the filesystem's resolution is coarse
+func _touch(t *testing.T, files ...string) {
+	later := time.Now().Add(time.Minute)
+	for _, each := range files {
+		require.NoError(t, os.Chtimes(each, later, later))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type command func(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error
+
+// Subcommands run instead of the server, e.g. `gccs render accounts production`
+var commands = map[string]command{
+	"lint":   lintCommand,
+	"render": renderCommand,
+}
+
+// usageError is reported without the command's name, as it follows the flags' own usage
+type usageError struct {
+	error
+}
+
+// Returns the process's exit code: 0 for success, 1 for failure, 2 for incorrect usage
+func runCommand(ctx context.Context, name string, args []string, stdout io.Writer, stderr io.Writer) int {
+	cmd, ok := commands[name]
+	if !ok {
+		var names []string
+		for each := range commands {
+			names = append(names, each)
+		}
+		sort.Strings(names)
+
+		_, _ = fmt.Fprintf(stderr, "unknown command [%s], expected one of: %s\n", name, strings.Join(names, ", "))
+		return 2
+	}
+
+	// Warnings, e.g. of missing values, are of interest, but not the server's usual logging
+	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: stderr, NoColor: true, PartsExclude: []string{zerolog.TimestampFieldName}}).Level(zerolog.WarnLevel)
+
+	err := cmd(ctx, args, stdout, stderr)
+
+	var usage usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usage):
+		if usage.error != nil {
+			_, _ = fmt.Fprintln(stderr, usage.Error())
+		}
+		return 2
+	default:
+		_, _ = fmt.Fprintf(stderr, "gccs %s: %v\n", name, err)
+		return 1
+	}
+}
+
+func newFlagSet(name string, usage string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: gccs %s %s\n\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// Parses flags wherever they appear, so they may follow the positional arguments too
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{} // already reported by `flags`
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
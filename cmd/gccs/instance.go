@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/GlintPay/gccs/api"
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/backend/setup"
+	"github.com/GlintPay/gccs/config"
+	"github.com/GlintPay/gccs/health"
+	"github.com/GlintPay/gccs/resolver/k8s"
+	"github.com/GlintPay/gccs/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// instance is everything built from one version of the configuration, serving until a reload replaces it
+type instance struct {
+	config     config.ApplicationConfiguration
+	gitConfig  config.GitConfig // as actually used, see `alternateBasedirs`
+	gitFiles   string           // digest of the Git credential files' contents, as the backends read them
+	backends   backend.Backends
+	dispatcher *webhook.Dispatcher
+	router     *chi.Mux
+	routing    *api.Routing
+	health     *health.Healthchecks
+
+	cancel context.CancelFunc // stops anything watching files etc.
+
+	lock     sync.Mutex
+	active   int
+	retiring bool
+	idle     chan struct{} // closed once retiring with nothing active
+}
+
+// Backends and the webhook dispatcher carry on from the previous instance unless their configuration, or the Git
+// credential files it names, changed, since recreating them means cloning again, or losing the delivery log
+func (rl *reloader) newInstance(cfg config.ApplicationConfiguration, previous *instance) (*instance, error) {
+	ctx, cancel := context.WithCancel(rl.ctx)
+	inst := &instance{config: cfg, cancel: cancel, idle: make(chan struct{})}
+
+	built := false
+	defer func() {
+		if !built {
+			inst.close(previous)
+		}
+	}()
+
+	inst.gitFiles = gitFilesDigest(cfg.Git)
+
+	if previous != nil && sameBackendConfig(previous.config, cfg) && previous.gitFiles == inst.gitFiles {
+		inst.backends = previous.backends
+		inst.gitConfig = previous.gitConfig
+	} else {
+		backendConfig := cfg
+		if previous != nil {
+			backendConfig.Git = alternateBasedirs(cfg.Git, previous.gitConfig)
+		}
+		inst.gitConfig = backendConfig.Git
+
+		backends, err := setup.Init(ctx, backendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("backend init failed: %w", err)
+		}
+		inst.backends = backends
+
+		for _, each := range backends {
+			if observable, ok := each.(backend.Observable); ok {
+				observable.Subscribe(rl.publish)
+			}
+		}
+	}
+
+	k8sClient, k8sResolver, err := setupK8s(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil && reflect.DeepEqual(previous.config.Webhooks, cfg.Webhooks) {
+		inst.dispatcher = previous.dispatcher
+	} else {
+		inst.dispatcher = setupWebhooks(cfg.Webhooks)
+	}
+
+	policies, err := setupPolicies(ctx, cfg.Auth, cfg.Git.DefaultBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	inst.router, inst.routing, err = setupRouter(cfg, inst.backends, k8sClient, k8sResolver, inst.dispatcher, policies, rl.adminHandler(cfg.Reload))
+	if err != nil {
+		return nil, err
+	}
+	inst.health = setupHealthCheck(ctx, inst.router, inst.backends, k8sResolver, rl.status)
+
+	built = true
+	return inst, nil
+}
+
+func (inst *instance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	inst.router.ServeHTTP(w, r)
+}
+
+// Counts the request as in flight, unless the instance is already retiring
+func (inst *instance) acquire() bool {
+	inst.lock.Lock()
+	defer inst.lock.Unlock()
+
+	if inst.retiring {
+		return false
+	}
+	inst.active++
+	return true
+}
+
+func (inst *instance) release() {
+	inst.lock.Lock()
+	defer inst.lock.Unlock()
+
+	inst.active--
+	if inst.retiring && inst.active == 0 {
+		close(inst.idle)
+	}
+}
+
+// Ends watches, waits for other requests to finish, then closes whatever `successor` isn't carrying on with
+func (inst *instance) retire(successor *instance) {
+	inst.lock.Lock()
+	inst.retiring = true
+	if inst.active == 0 {
+		close(inst.idle)
+	}
+	inst.lock.Unlock()
+
+	inst.routing.StopWatches()
+	<-inst.idle
+
+	inst.close(successor)
+}
+
+func (inst *instance) close(successor *instance) {
+	inst.cancel()
+
+	if successor == nil || !sameBackends(inst.backends, successor.backends) {
+		for _, each := range inst.backends {
+			each.Close()
+		}
+	}
+	if inst.dispatcher != nil && (successor == nil || successor.dispatcher != inst.dispatcher) {
+		inst.dispatcher.Close()
+	}
+}
+
+func sameBackendConfig(a config.ApplicationConfiguration, b config.ApplicationConfiguration) bool {
+	return reflect.DeepEqual(a.Git, b.Git) && reflect.DeepEqual(a.File, b.File) && a.Cache == b.Cache
+}
+
+// gitFilesDigest covers the contents of every key, credentials and known hosts file, so that any rotated in place
+// are picked up
+func gitFilesDigest(g config.GitConfig) string {
+	files := []string{g.PrivateKeyFile, g.CredentialsFile, g.KnownHostsFile}
+	for _, each := range g.Repos {
+		files = append(files, each.PrivateKeyFile, each.CredentialsFile, each.KnownHostsFile)
+	}
+
+	digest := sha256.New()
+	for _, each := range files {
+		if each == "" {
+			continue
+		}
+		contents, err := os.ReadFile(each)
+		if err != nil {
+			contents = []byte(err.Error()) // the backends will fail to read it too
+		}
+		_, _ = fmt.Fprintf(digest, "%s\x00%x\x00", each, sha256.Sum256(contents))
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+func sameBackends(a backend.Backends, b backend.Backends) bool {
+	return len(a) > 0 && len(b) > 0 && a[0] == b[0]
+}
+
+// New Git backends are initialised while the previous ones still serve, so can't clone into the same directory. They
+// take turns instead, between the configured directories and those suffixed `-reload`.
+func alternateBasedirs(g config.GitConfig, previous config.GitConfig) config.GitConfig {
+	if g.InMemory || g.Basedir == "" || g.Basedir != previous.Basedir {
+		return g
+	}
+
+	alternated := g
+	alternated.Basedir = g.Basedir + reloadSuffix
+	alternated.Repos = nil
+	for _, each := range g.Repos {
+		if each.Basedir != "" {
+			each.Basedir += reloadSuffix
+		}
+		alternated.Repos = append(alternated.Repos, each)
+	}
+	return alternated
+}
+
+const reloadSuffix = "-reload"
+
+// Shared by placeholder resolution and ServiceAccount authentication, whichever are enabled
+func setupK8s(cfg config.ApplicationConfiguration) (*k8s.Client, *k8s.Resolver, error) {
+	if !cfg.Kubernetes.Enabled && !cfg.Auth.Kubernetes.Enabled {
+		log.Info().Msg("K8s secret/configmap resolver disabled")
+		return nil, nil, nil
+	}
+
+	client, err := k8s.NewClient(cfg.Kubernetes)
+	if err != nil {
+		if cfg.Auth.Kubernetes.Enabled {
+			return nil, nil, fmt.Errorf("K8s client setup failed; required for ServiceAccount authentication: %w", err)
+		}
+		log.Warn().Err(err).Msg("K8s resolver setup failed; K8s placeholders will return errors")
+		return nil, nil, nil
+	}
+
+	if !cfg.Kubernetes.Enabled {
+		log.Info().Msg("K8s secret/configmap resolver disabled")
+		return client, nil, nil
+	}
+
+	log.Info().Msg("K8s secret/configmap resolver enabled")
+	return client, k8s.NewResolver(client, cfg.Kubernetes), nil
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GlintPay/gccs/api"
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/GlintPay/gccs/filetypes"
+	"github.com/GlintPay/gccs/utils"
+	"go.yaml.in/yaml/v3"
+)
+
+const lintUsage = `[flags]
+
+Checks every application and profile that the files of a configuration repository name, e.g. myapp-production.yml,
+for problems the server would otherwise only meet when asked for them. Exits with 1 if any are found.`
+
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+
+	ruleYAMLParse = "yaml-parse"
+)
+
+type lintRule struct {
+	id          string
+	level       string
+	description string
+}
+
+// In the order the SARIF output lists them
+var lintRules = []lintRule{
+	{id: ruleYAMLParse, level: levelError, description: "The file can't be parsed as YAML"},
+	{id: api.RuleUnresolvedPlaceholder, level: levelError, description: "A placeholder has no value and no default"},
+	{id: api.RulePlaceholderCycle, level: levelError, description: "Placeholders refer back to themselves, so can never be resolved"},
+	{id: api.RuleUnknownTemplateFunction, level: levelError, description: "A template calls a function that doesn't exist"},
+	{id: api.RuleInvalidTemplate, level: levelError, description: "A template can't be parsed or executed"},
+	{id: api.RulePointlessOverride, level: levelWarning, description: "A value is overridden by the same value, so needn't be"},
+}
+
+var lintFormats = map[string]func(io.Writer, []finding) error{
+	"text":  writeLintText,
+	"json":  writeLintJSON,
+	"sarif": writeSARIF,
+}
+
+// Exit with 1 for problems of at least this rank
+var failLevels = map[string]int{
+	levelWarning: 1,
+	levelError:   2,
+	"none":       3,
+}
+
+type finding struct {
+	Rule     string   `json:"rule"`
+	Level    string   `json:"level"`
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Key      string   `json:"key,omitempty"`
+	Message  string   `json:"message"`
+	Requests []string `json:"requests,omitempty"` // e.g. `myapp/production`, for problems found by resolving
+}
+
+func lintCommand(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("lint", lintUsage, stderr)
+
+	var opts renderOptions
+	opts.register(flags)
+	format := flags.String("format", "text", "text, json or sarif")
+	failOn := flags.String("fail-on", levelError, "the least severe problem to exit with 1 for: error, warning or none")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		flags.Usage()
+		return usageError{}
+	}
+
+	write, ok := lintFormats[*format]
+	if !ok {
+		return usageError{fmt.Errorf("unknown format [%s]", *format)}
+	}
+	threshold, ok := failLevels[*failOn]
+	if !ok {
+		return usageError{fmt.Errorf("unknown level [%s]", *failOn)}
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		return err
+	}
+
+	backends, err := opts.backends(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeAll(backends)
+
+	l := linter{
+		cfg:      cfg,
+		backends: backends,
+		label:    opts.label,
+		roots:    opts.roots(cfg),
+		files:    map[string]*lintedFile{},
+		findings: map[string]*finding{},
+	}
+	if e := l.lint(ctx); e != nil {
+		return e
+	}
+
+	findings := l.sorted()
+	if e := write(stdout, findings); e != nil {
+		return e
+	}
+
+	failures := 0
+	for _, each := range findings {
+		if failLevels[each.Level] >= threshold {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("problems found: %d", failures)
+	}
+	return nil
+}
+
+// Where files' fully qualified names start, to report them relative to the repository
+func (o *renderOptions) roots(cfg config.ApplicationConfiguration) []string {
+	roots := []string{o.source, cfg.Git.Uri}
+	if o.source != "" {
+		roots = append(roots, path.Clean(o.source))
+	}
+	for _, each := range cfg.Git.Repos {
+		roots = append(roots, each.Uri)
+	}
+	return roots
+}
+
+type linter struct {
+	cfg      config.ApplicationConfiguration
+	backends backend.Backends
+	label    string
+	roots    []string
+
+	files    map[string]*lintedFile // by fully qualified name
+	findings map[string]*finding    // by rule, file, key and message
+}
+
+type lintedFile struct {
+	path     string
+	name     string     // without its suffix, e.g. `myapp-production`
+	document *yaml.Node // nil if it can't be parsed
+}
+
+// An application, and the profiles to check it with one at a time
+type declaration struct {
+	application string
+	profiles    []string
+}
+
+func (l *linter) lint(ctx context.Context) error {
+	declarations, err := l.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if len(l.files) == 0 {
+		return fmt.Errorf("no configuration files found")
+	}
+
+	for _, each := range declarations {
+		for _, profile := range each.profiles {
+			if e := l.lintRequest(ctx, each.application, profile); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// Reads every file, then again with the applications and profiles found, for any search paths that name them
+func (l *linter) discover(ctx context.Context) ([]declaration, error) {
+	var declarations []declaration
+	for pass := 0; ; pass++ {
+		found := len(l.files)
+
+		var applications, profiles []string
+		for _, each := range declarations {
+			applications = append(applications, each.application)
+			profiles = append(profiles, each.profiles...)
+		}
+
+		for _, each := range l.backends {
+			state, err := each.GetCurrentState(ctx, applications, profiles, l.label, false)
+			if err != nil {
+				return nil, err
+			}
+			if e := state.Files.ForEach(l.addFile); e != nil {
+				return nil, e
+			}
+		}
+
+		declarations = l.declared()
+		if pass > 0 && len(l.files) == found {
+			return declarations, nil
+		}
+	}
+}
+
+func (l *linter) addFile(f backend.File) error {
+	readable, suffix := f.IsReadable()
+	if !readable {
+		return nil
+	}
+	if _, found := l.files[f.FullyQualifiedName()]; found {
+		return nil
+	}
+
+	lf := &lintedFile{path: l.relative(f.FullyQualifiedName()), name: strings.TrimSuffix(f.Name(), suffix)}
+	l.files[f.FullyQualifiedName()] = lf
+
+	// As the server parses it
+	if _, err := f.ToMap(); err != nil {
+		l.add(finding{Rule: ruleYAMLParse, File: lf.path, Line: errorLine(err), Message: err.Error()}, "")
+		return nil
+	}
+
+	bs, err := filetypes.ToBytes(f)
+	if err != nil {
+		return err
+	}
+	var document yaml.Node
+	if yaml.Unmarshal(bs, &document) == nil {
+		lf.document = &document
+	}
+	return nil
+}
+
+// Applications named by files, each with the `default` profile, its own, e.g. `production` from
+// `myapp-production.yml`, and every one shared through `application-{profile}.yml`. Where names overlap, e.g.
+// `myapp` and `myapp-api`, the shorter is the application.
+func (l *linter) declared() []declaration {
+	names := map[string]bool{}
+	shared := map[string]bool{}
+	for _, each := range l.files {
+		switch {
+		case each.name == utils.DefaultApplicationName:
+		case strings.HasPrefix(each.name, utils.DefaultApplicationNamePrefix):
+			shared[strings.TrimPrefix(each.name, utils.DefaultApplicationNamePrefix)] = true
+		default:
+			names[each.name] = true
+		}
+	}
+
+	profiles := map[string]map[string]bool{}
+	for name := range names {
+		if owner(name, names) == "" {
+			profiles[name] = map[string]bool{}
+		}
+	}
+	for name := range names {
+		if application := owner(name, profiles); application != "" {
+			profiles[application][name[len(application)+1:]] = true
+		}
+	}
+
+	var declarations []declaration
+	for application, own := range profiles {
+		for each := range shared {
+			own[each] = true
+		}
+		delete(own, "default")
+
+		d := declaration{application: application, profiles: []string{"default"}}
+		for each := range own {
+			d.profiles = append(d.profiles, each)
+		}
+		sort.Strings(d.profiles[1:])
+		declarations = append(declarations, d)
+	}
+
+	sort.Slice(declarations, func(i, j int) bool {
+		return declarations[i].application < declarations[j].application
+	})
+	return declarations
+}
+
+// The longest of the applications that the name is a profile of, if any
+func owner[V any](name string, applications map[string]V) string {
+	found := ""
+	for each := range applications {
+		if strings.HasPrefix(name, each+"-") && len(each) > len(found) {
+			found = each
+		}
+	}
+	return found
+}
+
+// Placeholders refer to flattened keys, so are checked as such
+func (l *linter) lintRequest(ctx context.Context, application string, profile string) error {
+	req := api.ConfigurationRequest{
+		Applications:          []string{application},
+		Profiles:              []string{profile},
+		Labels:                api.LabelsRequest{Branch: l.label},
+		FlattenHierarchies:    true,
+		FlattenedIndexedLists: l.cfg.Defaults.FlattenedIndexedLists,
+	}
+	request := application + "/" + profile
+
+	source, err := api.LoadConfigurations(ctx, l.backends, req)
+	if err != nil {
+		if l.unparseable() {
+			return nil // already reported
+		}
+		return fmt.Errorf("cannot load %s: %w", request, err)
+	}
+
+	for _, each := range api.NewResolver(req, l.cfg.Gotemplate, nil).Lint(req.Applications, req.Profiles, source) {
+		f := finding{Rule: each.Rule, File: l.relative(each.Source), Key: each.Key, Message: each.Message}
+		if lf, found := l.files[each.Source]; found {
+			f.Line = lf.line(each.Key)
+		}
+		l.add(f, request)
+	}
+	return nil
+}
+
+func (l *linter) unparseable() bool {
+	for _, each := range l.findings {
+		if each.Rule == ruleYAMLParse {
+			return true
+		}
+	}
+	return false
+}
+
+// Records each problem once, however many requests it was found by
+func (l *linter) add(f finding, request string) {
+	id := strings.Join([]string{f.Rule, f.File, f.Key, f.Message}, "\x00")
+	if existing, found := l.findings[id]; found {
+		if request != "" {
+			existing.Requests = append(existing.Requests, request)
+		}
+		return
+	}
+
+	for _, each := range lintRules {
+		if each.id == f.Rule {
+			f.Level = each.level
+		}
+	}
+	if request != "" {
+		f.Requests = []string{request}
+	}
+	l.findings[id] = &f
+}
+
+func (l *linter) sorted() []finding {
+	findings := make([]finding, 0, len(l.findings))
+	for _, each := range l.findings {
+		findings = append(findings, *each)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Rule != b.Rule:
+			return a.Rule < b.Rule
+		default:
+			return a.Key+a.Message < b.Key+b.Message
+		}
+	})
+	return findings
+}
+
+func (l *linter) relative(name string) string {
+	for _, each := range l.roots {
+		if rest, found := strings.CutPrefix(name, each+"/"); found && each != "" {
+			return rest
+		}
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+var errorLineRegex = regexp.MustCompile(`line (\d+)`)
+
+func errorLine(err error) int {
+	if match := errorLineRegex.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	return 0
+}
+
+// The line that sets a flattened key, e.g. `site.url` or `hosts[0]`, else 0
+func (f *lintedFile) line(key string) int {
+	if f.document == nil || len(f.document.Content) == 0 {
+		return 0
+	}
+	return findLine(f.document.Content[0], key)
+}
+
+func findLine(node *yaml.Node, key string) int {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i].Value, node.Content[i+1]
+
+			// Keys may contain dots themselves, so try each way of splitting
+			var line int
+			switch {
+			case key == name:
+				return node.Content[i].Line
+			case strings.HasPrefix(key, name+"."):
+				line = findLine(value, key[len(name)+1:])
+			case strings.HasPrefix(key, name+"["):
+				line = findLine(value, key[len(name):])
+			}
+			if line > 0 {
+				return line
+			}
+		}
+	case yaml.SequenceNode:
+		end := strings.Index(key, "]")
+		if !strings.HasPrefix(key, "[") || end < 0 {
+			return 0
+		}
+		index, err := strconv.Atoi(key[1:end])
+		if err != nil || index < 0 || index >= len(node.Content) {
+			return 0
+		}
+		if rest := strings.TrimPrefix(key[end+1:], "."); rest != "" {
+			return findLine(node.Content[index], rest)
+		}
+		return node.Content[index].Line
+	case yaml.AliasNode:
+		return findLine(node.Alias, key)
+	}
+	return 0
+}
+
+func writeLintText(w io.Writer, findings []finding) error {
+	for _, each := range findings {
+		location := each.File
+		if each.Line > 0 {
+			location += ":" + strconv.Itoa(each.Line)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s: %s (%s)\n", location, each.Level, each.Message, each.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLintJSON(w io.Writer, findings []finding) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(map[string]any{"problems": findings})
+}
+
+// SARIF 2.1.0, as taken by code scanning, e.g. GitHub's
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func writeSARIF(w io.Writer, findings []finding) error {
+	driver := sarifDriver{Name: "gccs", InformationUri: "https://github.com/GlintPay/glint-cloud-config-server"}
+	indexes := map[string]int{}
+	for i, each := range lintRules {
+		indexes[each.id] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			Id:                   each.id,
+			ShortDescription:     sarifMessage{Text: each.description},
+			DefaultConfiguration: sarifConfiguration{Level: each.level},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, each := range findings {
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{Uri: (&url.URL{Path: each.File}).String()}}}
+		if each.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: each.Line}
+		}
+
+		result := sarifResult{
+			RuleId:    each.Rule,
+			RuleIndex: indexes[each.Rule],
+			Level:     each.Level,
+			Message:   sarifMessage{Text: each.Message},
+			Locations: []sarifLocation{location},
+		}
+		if each.Key != "" {
+			result.Properties = map[string]any{"key": each.Key, "requests": each.Requests}
+		}
+		results = append(results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	_writeFile(t, dir, "accounts.yml", "site:\n  url: https://${host}\n  name: Accounts\nhosts:\n  - ${host:localhost}\n")
+	_writeFile(t, dir, "accounts-production.yml", "host: prod.com\nsite:\n  name: Accounts\n")
+	_writeFile(t, dir, "application.yml", "a: ${b}\nb: ${a}\nsuffix: \"{{ shout .Profiles }}\"\n")
+	_writeFile(t, dir, "application-broken.yml", "host: [oops\n")
+	_writeFile(t, dir, "README.md", "# Not configuration\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		code     int
+		expected string
+	}{
+		{
+			name: "text",
+			args: []string{"-source", dir},
+			code: 1,
+			expected: `accounts-production.yml:3: warning: [site.name] is already Accounts, so needn't be set again (pointless-override)
+accounts.yml:2: error: [site.url] refers to ${host}, which has no value or default (unresolved-placeholder)
+application-broken.yml:1: error: error converting YAML to JSON: yaml: line 1: did not find expected ',' or ']' (yaml-parse)
+application.yml:1: error: placeholders refer back to themselves: a -> b -> a (placeholder-cycle)
+application.yml:3: error: [suffix] calls unknown template function shout (unknown-template-function)
+`,
+		},
+		{
+			name: "warnings fail",
+			args: []string{"-source", dir, "-fail-on", "warning"},
+			code: 1,
+		},
+		{
+			name: "nothing fails",
+			args: []string{"-source", dir, "-fail-on", "none"},
+			code: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.code, runCommand(context.Background(), "lint", tt.args, &stdout, &stderr), stderr.String())
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestLintFormats(t *testing.T) {
+	dir := t.TempDir()
+	_writeFile(t, dir, "accounts.yml", "url: https://${host}\n")
+	_writeFile(t, dir, "accounts-production.yml", "other: ${host}\n")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 1, runCommand(context.Background(), "lint", []string{"-source", dir, "-format", "json"}, &stdout, &stderr))
+	assert.Equal(t, "gccs lint: problems found: 2\n", stderr.String())
+	assert.JSONEq(t, `{"problems": [
+		{"rule": "unresolved-placeholder", "level": "error", "file": "accounts-production.yml", "line": 1, "key": "other", "message": "[other] refers to ${host}, which has no value or default", "requests": ["accounts/production"]},
+		{"rule": "unresolved-placeholder", "level": "error", "file": "accounts.yml", "line": 1, "key": "url", "message": "[url] refers to ${host}, which has no value or default", "requests": ["accounts/default", "accounts/production"]}
+	]}`, stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 1, runCommand(context.Background(), "lint", []string{"-source", dir, "-format", "sarif"}, &stdout, &stderr))
+
+	var sarif sarifLog
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &sarif))
+	assert.Equal(t, "2.1.0", sarif.Version)
+	require.Len(t, sarif.Runs, 1)
+	assert.Len(t, sarif.Runs[0].Tool.Driver.Rules, len(lintRules))
+
+	result := sarif.Runs[0].Results[1]
+	assert.Equal(t, "unresolved-placeholder", result.RuleId)
+	assert.Equal(t, "unresolved-placeholder", sarif.Runs[0].Tool.Driver.Rules[result.RuleIndex].Id)
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, "accounts.yml", result.Locations[0].PhysicalLocation.ArtifactLocation.Uri)
+	assert.Equal(t, 1, result.Locations[0].PhysicalLocation.Region.StartLine)
+
+	// Clean
+	_writeFile(t, dir, "application.yml", "host: test.com\n")
+	stdout.Reset()
+	assert.Equal(t, 0, runCommand(context.Background(), "lint", []string{"-source", dir, "-format", "json"}, &stdout, &stderr))
+	assert.JSONEq(t, `{"problems": []}`, stdout.String())
+}
+
+func TestLintFailures(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{
+			name:   "unexpected argument",
+			args:   []string{"-source", dir, "accounts"},
+			code:   2,
+			stderr: "Usage: gccs lint",
+		},
+		{
+			name:   "unknown format",
+			args:   []string{"-source", dir, "-format", "xml"},
+			code:   2,
+			stderr: "unknown format [xml]",
+		},
+		{
+			name:   "unknown level",
+			args:   []string{"-source", dir, "-fail-on", "info"},
+			code:   2,
+			stderr: "unknown level [info]",
+		},
+		{
+			name:   "search paths without label",
+			args:   []string{"-source", dir, "-search-paths", "shared"},
+			code:   2,
+			stderr: "search paths need a -label",
+		},
+		{
+			name:   "empty",
+			args:   []string{"-source", dir},
+			code:   1,
+			stderr: "no configuration files found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.code, runCommand(context.Background(), "lint", tt.args, &stdout, &stderr))
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+}
+
+func TestDeclared(t *testing.T) {
+	l := linter{files: map[string]*lintedFile{}}
+	for _, each := range []string{"application", "application-production", "accounts", "accounts-eu", "accounts-api", "accounts-api-eu", "payments-default", "security-default"} {
+		l.files[each] = &lintedFile{name: each}
+	}
+	l.files["security"] = &lintedFile{name: "security"}
+
+	assert.Equal(t, []declaration{
+		{application: "accounts", profiles: []string{"default", "api", "api-eu", "eu", "production"}},
+		{application: "payments-default", profiles: []string{"default", "production"}},
+		{application: "security", profiles: []string{"default", "production"}},
+	}, l.declared())
+}
+
+func TestFindLine(t *testing.T) {
+	var document yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`site:
+  url: https://a.com
+  a.b: dotted
+hosts:
+  - name: a
+  - name: b
+    ports:
+      - 80
+      - 443
+`), &document))
+	f := lintedFile{document: &document}
+
+	tests := map[string]int{
+		"site":                1,
+		"site.url":            2,
+		"site.a.b":            3,
+		"hosts":               4,
+		"hosts[0].name":       5,
+		"hosts[1]":            6,
+		"hosts[1].ports[1]":   9,
+		"hosts[2]":            0,
+		"missing":             0,
+		"site.url.impossible": 0,
+	}
+	for key, line := range tests {
+		assert.Equal(t, line, f.line(key), key)
+	}
+}
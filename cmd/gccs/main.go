@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/GlintPay/gccs/api"
+	"github.com/GlintPay/gccs/auth"
 	"github.com/GlintPay/gccs/backend"
-	"github.com/GlintPay/gccs/backend/setup"
+	"github.com/GlintPay/gccs/certs"
 	"github.com/GlintPay/gccs/config"
 	"github.com/GlintPay/gccs/health"
 	"github.com/GlintPay/gccs/logging"
+	"github.com/GlintPay/gccs/monitor"
 	"github.com/GlintPay/gccs/resolver/k8s"
-	"github.com/GlintPay/gccs/utils"
+	"github.com/GlintPay/gccs/webhook"
 	"github.com/caarlos0/env/v6"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -26,8 +32,6 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
-	"golang.org/x/sync/errgroup"
-	"sigs.k8s.io/yaml"
 )
 
 const serviceName = "gccs"
@@ -35,12 +39,18 @@ const serviceName = "gccs"
 var envConfig = config.Configuration{}
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(context.Background(), os.Args[1], os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	if err := env.Parse(&envConfig); err != nil {
 		log.Fatal().Msgf("Configuration loading failed: %+v", err)
 	}
 
-	appConfig := config.ApplicationConfiguration{}
-	readConfig(envConfig.ApplicationConfigFileYmlPath, &appConfig)
+	appConfig, err := loadConfig(envConfig.ApplicationConfigFileYmlPath, envConfig.StrictApplicationConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Configuration loading failed")
+	}
 
 	logging.Setup(os.Stdout)
 
@@ -49,72 +59,73 @@ func main() {
 
 	////////////////////////////////////////////
 
-	backends, backendErr := setup.Init(ctx, appConfig)
-	if backendErr != nil {
-		log.Fatal().Stack().Err(backendErr).Msg("Backend init failed")
+	traceShutdown, err := setupTracing(ctx, appConfig)
+	if err != nil {
+		log.Fatal().Stack().Err(err).Msg("Trace setup failed")
 	}
 
-	////////////////////////////////////////////
-
-	var k8sResolver *k8s.Resolver
-	var e error
-	if appConfig.Kubernetes.Enabled {
-		k8sResolver, e = setupK8sResolver(appConfig.Kubernetes)
-		if e != nil {
-			log.Warn().Err(e).Msg("K8s resolver setup failed; K8s placeholders will return errors")
-		}
-	} else {
-		log.Info().Msg("K8s secret/configmap resolver disabled")
+	rl := newReloader(ctx, envConfig.ApplicationConfigFileYmlPath, envConfig.StrictApplicationConfig)
+	if err = rl.start(appConfig); err != nil {
+		log.Fatal().Stack().Err(err).Msg("Setup failed")
 	}
+	go rl.watch(ctx, appConfig.Reload)
 
 	////////////////////////////////////////////
 
-	var traceShutdown func()
-	traceShutdown, e = setupTracing(ctx, appConfig)
-	if e != nil {
-		log.Fatal().Stack().Err(e).Msg("Trace setup failed")
-	}
-	defer traceShutdown()
-
-	router := setupRouter(appConfig, backends, k8sResolver)
-	setupHealthCheck(router)
+	signals, stopSignals := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
 
-	////////////////////////////////////////////
-
-	g, _ := errgroup.WithContext(ctx)
-	g.Go(func() error {
-		if appConfig.Server.Port == 0 {
-			appConfig.Server.Port = 80
-		}
-		port := fmt.Sprintf(":%d", appConfig.Server.Port)
-		log.Info().Msgf("Listening on %s", port)
-		if err := http.ListenAndServe(port, router); err != nil {
-			return fmt.Errorf("http server: %w", err)
-		}
-		return nil
+	server := newServer(appConfig.Server, rl)
+	server.RegisterOnShutdown(func() {
+		rl.current.Load().routing.StopWatches()
 	})
 
-	err := g.Wait()
-	if err != nil {
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, appConfig.Server, server)
+	}()
+
+	select {
+	case err = <-served:
 		log.Fatal().Stack().Err(err).Msg("startup failed")
+	case <-signals.Done():
 	}
+
+	rl.lock.Lock() // no more reloads
+	current := rl.current.Load()
+
+	shutdown(appConfig.Server, server, current.health)
+
+	cancel() // stops anything watching files, and pending K8s / Git calls
+	current.close(nil)
+	<-rl.retired
+	traceShutdown()
+
+	log.Info().Msg("Shutdown complete")
 }
 
-func readConfig(filePath string, config *config.ApplicationConfiguration) {
-	yamlFile, err := os.ReadFile(filePath)
-	if err == nil {
-		log.Debug().Msgf("Loading YAML config from %s", utils.FriendlyFileName(filePath))
-		err = yaml.Unmarshal(yamlFile, config)
-		if err != nil {
-			log.Fatal().Stack().Err(err).Msg("Unmarshal")
-		}
-	} else {
-		log.Printf("No config file found: %s", utils.FriendlyFileName(filePath))
+// Fails readiness, then stops accepting requests once load balancers should have noticed, giving those in flight
+// until the grace period ends
+func shutdown(cfg config.Server, server *http.Server, healthChk *health.Healthchecks) {
+	cfg = cfg.Validate()
+
+	log.Info().Msgf("Shutting down, in %d ms", cfg.ShutdownDelayMillis)
+	healthChk.ShuttingDown()
+	time.Sleep(time.Duration(cfg.ShutdownDelayMillis) * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownGraceMillis)*time.Millisecond)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Requests still in flight at the end of the grace period")
+		_ = server.Close()
 	}
 }
 
 var emptyShutdown = func() {}
 
+const traceFlushTimeout = 5 * time.Second
+
 func setupTracing(ctx context.Context, config config.ApplicationConfiguration) (func(), error) {
 	if !config.Tracing.Enabled {
 		return emptyShutdown, nil
@@ -153,23 +164,110 @@ func setupTracing(ctx context.Context, config config.ApplicationConfiguration) (
 
 	log.Info().Msgf("OpenTelemetry export is enabled, to: %s", config.Tracing.Endpoint)
 
+	// Flushes any spans still batched, so can't use `ctx`, which is cancelled by then
 	return func() {
-		if err = tracerProvider.Shutdown(ctx); err != nil {
-			log.Fatal().Stack().Err(err).Msg("failed to shutdown TracerProvider")
+		flushCtx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+		defer cancel()
+
+		if err = tracerProvider.Shutdown(flushCtx); err != nil {
+			log.Error().Stack().Err(err).Msg("failed to shutdown TracerProvider")
 		}
 	}, nil
 }
 
-func setupK8sResolver(cfg config.K8sConfig) (*k8s.Resolver, error) {
-	client, err := k8s.NewClient(cfg)
+// Backends' changes reach the dispatcher via `reloader.publish`
+func setupWebhooks(cfg config.Webhooks) *webhook.Dispatcher {
+	if len(cfg.Endpoints) == 0 {
+		return nil
+	}
+
+	dispatcher := webhook.New(cfg)
+
+	log.Info().Msgf("Sending change events to %d webhook(s)", len(cfg.Endpoints))
+	return dispatcher
+}
+
+func setupPolicies(ctx context.Context, cfg config.Auth, defaultLabel string) (*auth.Policies, error) {
+	if cfg.PoliciesFile == "" {
+		return nil, nil
+	}
+
+	cfg = cfg.Validate()
+
+	policies, err := auth.LoadPolicies(cfg.PoliciesFile, defaultLabel)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("authorization policies failed to load: %w", err)
+	}
+	policies.Watch(ctx, time.Duration(cfg.PoliciesReloadMillis)*time.Millisecond)
+
+	return policies, nil
+}
+
+func newServer(cfg config.Server, router http.Handler) *http.Server {
+	cfg = cfg.Validate()
+
+	return &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutMillis) * time.Millisecond,
+		ReadTimeout:       time.Duration(cfg.ReadTimeoutMillis) * time.Millisecond,
+		WriteTimeout:      time.Duration(cfg.WriteTimeoutMillis) * time.Millisecond,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutMillis) * time.Millisecond,
 	}
-	log.Info().Msg("K8s secret/configmap resolver enabled")
-	return k8s.NewResolver(client, cfg), nil
 }
 
-func setupRouter(config config.ApplicationConfiguration, backends backend.Backends, k8sResolver *k8s.Resolver) *chi.Mux {
+// Returns once the server has been shut down, else if it couldn't start
+func serve(ctx context.Context, cfg config.Server, server *http.Server) error {
+	var err error
+
+	if !cfg.Tls.Enabled() {
+		server.Addr = listenAddress(cfg.Port, 80)
+		log.Info().Msgf("Listening on %s", server.Addr)
+
+		if err = server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("http server: %w", err)
+		}
+		return nil
+	}
+
+	tlsConfig := cfg.Tls.Validate()
+
+	reloader, err := certs.New(tlsConfig)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	reloader.Watch(ctx, time.Duration(tlsConfig.ReloadMillis)*time.Millisecond)
+
+	server.Addr = listenAddress(cfg.Port, 443)
+	server.TLSConfig = reloader.TLSConfig()
+
+	log.Info().Msgf("Listening with TLS on %s", server.Addr)
+	if err = server.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("https server: %w", err)
+	}
+	return nil
+}
+
+func listenAddress(port int, defaultPort int) string {
+	if port == 0 {
+		port = defaultPort
+	}
+	return fmt.Sprintf(":%d", port)
+}
+
+func setupRouter(config config.ApplicationConfiguration, backends backend.Backends, k8sClient *k8s.Client, k8sResolver *k8s.Resolver, dispatcher *webhook.Dispatcher, policies *auth.Policies, reload http.HandlerFunc) (*chi.Mux, *api.Routing, error) {
+	var authOpts []auth.Option
+	if k8sClient != nil {
+		authOpts = append(authOpts, auth.WithTokenReviewer(k8sClient))
+	}
+
+	authenticator, err := auth.New(config.Auth, authOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("auth setup failed: %w", err)
+	}
+	if authenticator == nil {
+		log.Warn().Msg("No authentication configured: configuration is readable by anyone who can connect")
+	}
+
 	router := chi.NewRouter()
 	router.Use(middleware.StripSlashes)
 
@@ -181,15 +279,53 @@ func setupRouter(config config.ApplicationConfiguration, backends backend.Backen
 		AppConfig:   config,
 		K8sResolver: k8sResolver,
 	}
+	if policies != nil {
+		routing.Authorizer = policies
+	}
 
+	var routeErr error
 	router.Route("/", func(r chi.Router) {
 		r.Use(httplog.Handler(log.Logger))
 		r.Use(middleware.RequestID)
 		r.Use(middleware.Compress(5))
 
-		if e := routing.SetupFunctionalRoutes(r); e != nil {
-			log.Fatal().Stack().Err(e).Msg("route setup failed")
+		if config.Monitor.Enabled {
+			log.Info().Msg("Registering monitor endpoint at: /monitor")
+			r.Post("/monitor", monitor.Handler(config.Monitor, backends))
 		}
+
+		r.Group(func(r chi.Router) {
+			if authenticator != nil {
+				r.Use(authenticator.Middleware)
+			}
+
+			// Admin endpoints are only for callers that policies allow, so need authentication
+			if authenticator == nil {
+				if dispatcher != nil || reload != nil {
+					log.Warn().Msg("No authentication configured, so the admin endpoints aren't registered")
+				}
+			} else {
+				r.Group(func(r chi.Router) {
+					if policies == nil && (dispatcher != nil || reload != nil) {
+						log.Warn().Msg("No authorization policies, so nobody may use the admin endpoints")
+					}
+					r.Use(policies.RequireAdmin)
+
+					if dispatcher != nil {
+						r.Get("/webhooks/deliveries", dispatcher.DeliveriesHandler())
+					}
+
+					if reload != nil {
+						log.Info().Msg("Registering reload endpoint at: /admin/reload")
+						r.Post("/admin/reload", reload)
+					}
+				})
+			}
+
+			if e := routing.SetupFunctionalRoutes(r); e != nil {
+				routeErr = fmt.Errorf("route setup failed: %w", e)
+			}
+		})
 	})
 
 	if len(config.Prometheus.Path) > 0 {
@@ -197,10 +333,27 @@ func setupRouter(config config.ApplicationConfiguration, backends backend.Backen
 		router.Handle(config.Prometheus.Path, promhttp.Handler())
 	}
 
-	return router
+	return router, &routing, routeErr
 }
 
-func setupHealthCheck(router *chi.Mux) {
-	healthChk := health.New(health.WithChiMux(router))
+func setupHealthCheck(ctx context.Context, router *chi.Mux, backends backend.Backends, k8sResolver *k8s.Resolver, reloadStatus func() (any, error)) *health.Healthchecks {
+	opts := []health.Opt{health.WithChiMux(router)}
+
+	for _, each := range backends {
+		opts = append(opts, health.WithReadinessCheck("backend:"+each.Name(), func() (any, error) {
+			return each.Health(ctx)
+		}))
+	}
+
+	if k8sResolver != nil {
+		opts = append(opts, health.WithReadinessCheck("k8s", func() (any, error) {
+			return nil, k8sResolver.Ping(ctx)
+		}))
+	}
+
+	opts = append(opts, health.WithReadinessCheck("config", reloadStatus))
+
+	healthChk := health.New(opts...)
 	healthChk.StartListening()
+	return healthChk
 }
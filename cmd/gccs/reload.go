@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/GlintPay/gccs/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// reloader serves requests from the instance built from the latest valid configuration, replacing it whenever the
+// file is reloaded. Requests already in flight finish on the instance they started on.
+type reloader struct {
+	ctx      context.Context
+	file     string
+	required bool // as at startup, else a missing file means configuring from the environment alone
+
+	lock    sync.Mutex // one reload at a time
+	current atomic.Pointer[instance]
+	retired chan struct{} // closed once the last instance replaced has been closed
+
+	modTime time.Time
+	size    int64
+
+	statusLock sync.Mutex
+	lastReload time.Time
+	lastError  error
+}
+
+var reloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "gccs",
+	Subsystem: "config",
+	Name:      "reloads_total",
+	Help:      "Attempts to reload the application configuration, by whether it was applied",
+}, []string{"result"})
+
+var errRetiring = errors.New("the previous configuration is still draining, try again shortly")
+
+func newReloader(ctx context.Context, file string, required bool) *reloader {
+	retired := make(chan struct{})
+	close(retired)
+	return &reloader{ctx: ctx, file: file, required: required, retired: retired}
+}
+
+// Start serving `cfg`, as loaded at startup
+func (rl *reloader) start(cfg config.ApplicationConfiguration) error {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rl.recordFileInfo()
+
+	inst, err := rl.newInstance(cfg, nil)
+	if err != nil {
+		return err
+	}
+	rl.current.Store(inst)
+	return nil
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for {
+		// Only refused once retiring, by which time its successor is current
+		inst := rl.current.Load()
+		if inst.acquire() {
+			defer inst.release()
+			inst.ServeHTTP(w, r)
+			return
+		}
+	}
+}
+
+// Reload the configuration file, replacing the current instance only if the new configuration is valid and everything
+// it configures can be set up
+func (rl *reloader) Reload(trigger string) error {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	select {
+	case <-rl.retired:
+	default:
+		// Its Git directories may be needed again
+		log.Warn().Msgf("Not reloading configuration (%s) yet: %v", trigger, errRetiring)
+		return errRetiring
+	}
+
+	err := rl.reload()
+	if err != nil {
+		reloads.WithLabelValues("rejected").Inc()
+		log.Error().Err(err).Msgf("Keeping the running configuration, as %s (%s) is invalid", utils.FriendlyFileName(rl.file), trigger)
+	} else {
+		reloads.WithLabelValues("applied").Inc()
+		log.Info().Msgf("Reloaded configuration from %s (%s)", utils.FriendlyFileName(rl.file), trigger)
+	}
+
+	rl.statusLock.Lock()
+	rl.lastReload = time.Now()
+	rl.lastError = err
+	rl.statusLock.Unlock()
+
+	return err
+}
+
+func (rl *reloader) reload() error {
+	// Recorded even if invalid, so an error is only reported once per change
+	rl.recordFileInfo()
+
+	cfg, err := loadConfig(rl.file, rl.required)
+	if err != nil {
+		return err
+	}
+
+	previous := rl.current.Load()
+	if !reflect.DeepEqual(previous.config.Server, cfg.Server) || !reflect.DeepEqual(previous.config.Tracing, cfg.Tracing) {
+		log.Warn().Msg("Changes to `server` and `tracing` only take effect on restart")
+	}
+
+	next, err := rl.newInstance(cfg, previous)
+	if err != nil {
+		return err
+	}
+	rl.current.Store(next)
+
+	retired := make(chan struct{})
+	rl.retired = retired
+	go func() {
+		defer close(retired)
+		previous.retire(next)
+	}()
+	return nil
+}
+
+func (rl *reloader) recordFileInfo() {
+	if info, err := os.Stat(rl.file); err == nil {
+		rl.modTime = info.ModTime()
+		rl.size = info.Size()
+	}
+}
+
+// Forwards changes picked up by any backend to the current webhook dispatcher
+func (rl *reloader) publish(change backend.Change) {
+	if inst := rl.current.Load(); inst != nil && inst.dispatcher != nil {
+		inst.dispatcher.Publish(change)
+	}
+}
+
+// Reported with readiness, but never failing it, since the previous configuration is still served
+func (rl *reloader) status() (any, error) {
+	rl.statusLock.Lock()
+	defer rl.statusLock.Unlock()
+
+	if rl.lastReload.IsZero() {
+		return nil, nil
+	}
+
+	detail := map[string]any{"lastReload": rl.lastReload}
+	if rl.lastError != nil {
+		detail["error"] = rl.lastError.Error()
+	}
+	return detail, nil
+}
+
+func (rl *reloader) adminHandler(cfg config.Reload) http.HandlerFunc {
+	if !cfg.Endpoint {
+		return nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", applicationJSON)
+
+		if err := rl.Reload("admin request"); err != nil {
+			if errors.Is(err, errRetiring) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"message": err.Error()})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"message": "reloaded"})
+	}
+}
+
+const applicationJSON = "application/json"
+
+// Reload on `SIGHUP`, and if configured whenever the file changes, until `ctx` is done
+func (rl *reloader) watch(ctx context.Context, cfg config.Reload) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+	if cfg.Watch {
+		ticker := time.NewTicker(time.Duration(cfg.Validate().IntervalMillis) * time.Millisecond)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			signal.Stop(hangups)
+			return
+		case <-hangups:
+			_ = rl.Reload("SIGHUP")
+		case <-ticks:
+			if rl.changed() {
+				_ = rl.Reload("file changed")
+			}
+		}
+	}
+}
+
+func (rl *reloader) changed() bool {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	info, err := os.Stat(rl.file)
+	return err == nil && (!info.ModTime().Equal(rl.modTime) || info.Size() != rl.size)
+}
+
+// Loads the file, overridden by any environment variables, erroring if it has fields we don't recognise, e.g.
+// misspelt, or the result is invalid. Unless `required`, a missing file is treated as empty, so everything can come
+// from the environment, else the error wraps `fs.ErrNotExist`.
+func loadConfig(filePath string, required bool) (config.ApplicationConfiguration, error) {
+	cfg := config.ApplicationConfiguration{}
+
+	yamlFile, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		log.Warn().Msgf("No config file found: %s, configuring from the environment", utils.FriendlyFileName(filePath))
+	} else if err != nil {
+		return cfg, err
+	} else {
+		log.Debug().Msgf("Loading YAML config from %s", utils.FriendlyFileName(filePath))
+		if err = yaml.UnmarshalStrict(yamlFile, &cfg); err != nil {
+			return cfg, fmt.Errorf("unparseable config %s: %w", utils.FriendlyFileName(filePath), err)
+		}
+	}
+
+	overridden, err := cfg.Override(os.LookupEnv)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid environment overrides:\n%w", err)
+	}
+	if len(overridden) > 0 {
+		log.Info().Msgf("Configuration overridden by %s", strings.Join(overridden, ", "))
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s:\n%w", utils.FriendlyFileName(filePath), err)
+	}
+	return cfg, nil
+}
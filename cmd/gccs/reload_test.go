@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestReload(t *testing.T) {
+	first := _configDir(t, "site:\n  url: https://first.com\n")
+	second := _configDir(t, "site:\n  url: https://second.com\n")
+
+	policies := filepath.Join(t.TempDir(), "policies.yml")
+	require.NoError(t, os.WriteFile(policies, []byte("policies:\n  - name: operators\n    principals: [ops]\n    admin: true\n"), 0o644))
+	authConfig := "auth:\n  bearer:\n    tokens:\n      ops: s3cret\n  policiesFile: " + policies + "\n"
+
+	file := filepath.Join(t.TempDir(), "application.yml")
+	_writeAppConfig(t, file, "git:\n  disabled: true\nfile:\n  path: "+first+"\nreload:\n  endpoint: true\n"+authConfig)
+
+	rl := _start(t, file)
+	assert.Equal(t, "https://first.com", _siteUrl(t, rl))
+
+	tests := []struct {
+		name     string
+		config   string
+		code     int
+		expected string
+	}{
+		{
+			name:     "unparseable",
+			config:   "file: [",
+			code:     http.StatusUnprocessableEntity,
+			expected: "https://first.com",
+		},
+		{
+			name:     "cannot be set up",
+			config:   "git:\n  disabled: true\nfile:\n  path: " + second + "\nauth:\n  bearer:\n    tokens:\n      ci: secret\n  policiesFile: /policies.yml\nreload:\n  endpoint: true\n",
+			code:     http.StatusUnprocessableEntity,
+			expected: "https://first.com",
+		},
+		{
+			name:     "invalid",
+			config:   "git:\n  uri: git@github.com:Org/config.git\nfile:\n  path: " + second + "\nreload:\n  endpoint: true\n" + authConfig,
+			code:     http.StatusUnprocessableEntity,
+			expected: "https://first.com",
+		},
+		{
+			name:     "valid",
+			config:   "git:\n  disabled: true\nfile:\n  path: " + second + "\nreload:\n  endpoint: true\n" + authConfig,
+			code:     http.StatusOK,
+			expected: "https://second.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			<-rl.retired
+			_writeAppConfig(t, file, tt.config)
+
+			rr := httptest.NewRecorder()
+			rl.ServeHTTP(rr, _authenticated(httptest.NewRequest("POST", "/admin/reload", nil)))
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+
+			assert.Equal(t, tt.expected, _siteUrl(t, rl))
+		})
+	}
+
+	detail, err := rl.status()
+	assert.NoError(t, err)
+	assert.NotContains(t, detail, "error")
+}
+
+func TestReloadOnChange(t *testing.T) {
+	first := _configDir(t, "site:\n  url: https://first.com\n")
+	second := _configDir(t, "site:\n  url: https://second.com\n")
+
+	file := filepath.Join(t.TempDir(), "application.yml")
+	_writeAppConfig(t, file, "git:\n  disabled: true\nfile:\n  path: "+first+"\nreload:\n  endpoint: true\n")
+
+	rl := _start(t, file)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rl.watch(ctx, config.Reload{Watch: true, IntervalMillis: 10})
+
+	// No endpoint without authentication, even if configured
+	rr := httptest.NewRecorder()
+	rl.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	_writeAppConfig(t, file, "git:\n  disabled: true\nfile:\n  path: "+second+"\n")
+
+	assert.Eventually(t, func() bool {
+		return _siteUrl(t, rl) == "https://second.com"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReloadFromEnvironment(t *testing.T) {
+	first := _configDir(t, "site:\n  url: https://first.com\n")
+	second := _configDir(t, "site:\n  url: https://second.com\n")
+	file := filepath.Join(t.TempDir(), "missing.yml")
+
+	t.Setenv("GIT_DISABLED", "true")
+	t.Setenv("FILE_PATH", first)
+
+	cfg, err := loadConfig(file, false)
+	require.NoError(t, err)
+
+	rl := newReloader(context.Background(), file, false)
+	require.NoError(t, rl.start(cfg))
+	assert.Equal(t, "https://first.com", _siteUrl(t, rl))
+
+	t.Setenv("FILE_PATH", second)
+	require.NoError(t, rl.Reload("test"))
+	assert.Equal(t, "https://second.com", _siteUrl(t, rl))
+
+	// Strict as at startup
+	strict := newReloader(context.Background(), file, true)
+	require.NoError(t, strict.start(cfg))
+	assert.ErrorIs(t, strict.Reload("test"), fs.ErrNotExist)
+	assert.Equal(t, "https://first.com", _siteUrl(t, strict))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{
+			name:     "valid",
+			contents: "git:\n  disabled: true\nfile:\n  path: " + dir + "\n",
+		},
+		{
+			name:     "misspelt",
+			contents: "git:\n  uri: git@github.com:Org/config.git\n  basedir: /tmp/cloud-config\n  refresh-rate: 5000\nfile:\n  path: " + dir + "\n",
+			expected: `unknown field "refresh-rate"`,
+		},
+		{
+			name:     "invalid",
+			contents: "git:\n  uri: git@github.com:Org/config.git\nfile:\n  path: " + dir + "\n",
+			expected: "git.basedir is required, unless cloning inMemory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "application.yml")
+			_writeAppConfig(t, file, tt.contents)
+
+			_, err := loadConfig(file, true)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expected)
+			}
+		})
+	}
+
+	_, err := loadConfig(filepath.Join(dir, "missing.yml"), true)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Configured from the environment instead
+	t.Setenv("GIT_DISABLED", "true")
+	t.Setenv("FILE_PATH", dir)
+
+	cfg, err := loadConfig(filepath.Join(dir, "missing.yml"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, cfg.File.Path)
+}
+
+func TestRetireWaitsForRequests(t *testing.T) {
+	rl := _start(t, filepath.Join(t.TempDir(), "application.yml"))
+	inst := rl.current.Load()
+
+	require.True(t, inst.acquire())
+
+	retired := make(chan struct{})
+	go func() {
+		inst.retire(nil)
+		close(retired)
+	}()
+
+	assert.Never(t, func() bool {
+		select {
+		case <-retired:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, 5*time.Millisecond)
+
+	assert.False(t, inst.acquire())
+	inst.release()
+
+	select {
+	case <-retired:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "not retired")
+	}
+}
+
+func TestReloadPicksUpRotatedGitKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	_writeKey(t, keyFile)
+
+	cfg := config.ApplicationConfiguration{
+		Git: config.GitConfig{
+			Uri:                             "git@github.com:Org/config.git",
+			Basedir:                         t.TempDir(),
+			PrivateKeyFile:                  keyFile,
+			InsecureSkipHostKeyVerification: true,
+		},
+		File: config.FileConfig{Disabled: true},
+	}
+
+	rl := newReloader(context.Background(), "", true)
+
+	first, err := rl.newInstance(cfg, nil)
+	require.NoError(t, err)
+
+	unchanged, err := rl.newInstance(cfg, first)
+	require.NoError(t, err)
+	assert.Same(t, first.backends[0], unchanged.backends[0])
+
+	_writeKey(t, keyFile) // rotated in place
+
+	rotated, err := rl.newInstance(cfg, unchanged)
+	require.NoError(t, err)
+	defer rotated.close(nil)
+	assert.NotSame(t, unchanged.backends[0], rotated.backends[0])
+}
+
+func TestAlternateBasedirs(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.GitConfig
+		previous config.GitConfig
+		expected config.GitConfig
+	}{
+		{
+			name:     "in memory",
+			config:   config.GitConfig{InMemory: true},
+			previous: config.GitConfig{InMemory: true},
+			expected: config.GitConfig{InMemory: true},
+		},
+		{
+			name:     "different directory",
+			config:   config.GitConfig{Basedir: "/tmp/b"},
+			previous: config.GitConfig{Basedir: "/tmp/a"},
+			expected: config.GitConfig{Basedir: "/tmp/b"},
+		},
+		{
+			name:     "same directory",
+			config:   config.GitConfig{Basedir: "/tmp/a", Repos: []config.GitRepoConfig{{Name: "x"}, {Name: "y", Basedir: "/tmp/y"}}},
+			previous: config.GitConfig{Basedir: "/tmp/a"},
+			expected: config.GitConfig{Basedir: "/tmp/a-reload", Repos: []config.GitRepoConfig{{Name: "x"}, {Name: "y", Basedir: "/tmp/y-reload"}}},
+		},
+		{
+			name:     "back again",
+			config:   config.GitConfig{Basedir: "/tmp/a"},
+			previous: config.GitConfig{Basedir: "/tmp/a-reload"},
+			expected: config.GitConfig{Basedir: "/tmp/a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, alternateBasedirs(tt.config, tt.previous))
+		})
+	}
+}
+
+func _start(t *testing.T, file string) *reloader {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg, err := loadConfig(file, true)
+	if err != nil {
+		cfg = config.ApplicationConfiguration{Git: config.GitConfig{Disabled: true}, File: config.FileConfig{Disabled: true}}
+	}
+
+	rl := newReloader(ctx, file, true)
+	require.NoError(t, rl.start(cfg))
+	return rl
+}
+
+func _siteUrl(t *testing.T, rl *reloader) string {
+	rr := httptest.NewRecorder()
+	rl.ServeHTTP(rr, _authenticated(httptest.NewRequest("GET", "/accounts/production?resolve=true&flatten=true", nil)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var values map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &values))
+	return values["site.url"].(string)
+}
+
+// As `ops`, whenever authentication is configured
+func _authenticated(r *http.Request) *http.Request {
+	r.Header.Set("Authorization", "Bearer s3cret")
+	return r
+}
+
+func _writeKey(t *testing.T, file string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(block), 0o600))
+}
+
+func _configDir(t *testing.T, contents string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "accounts.yml"), []byte(contents), 0o644))
+	return dir
+}
+
+// Also advances the modification time, which may otherwise not change between quick writes
+func _writeAppConfig(t *testing.T, file string, contents string) {
+	require.NoError(t, os.WriteFile(file, []byte(contents), 0o644))
+
+	modTime := time.Now().Add(time.Duration(len(contents)) * time.Second)
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
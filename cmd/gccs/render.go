@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/GlintPay/gccs/api"
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/backend/file"
+	"github.com/GlintPay/gccs/backend/git"
+	"github.com/GlintPay/gccs/backend/setup"
+	"github.com/GlintPay/gccs/config"
+	"github.com/GlintPay/gccs/utils"
+	goGit "github.com/go-git/go-git/v5"
+	"sigs.k8s.io/yaml"
+)
+
+const renderUsage = `[flags] <applications> [<profiles>]
+
+Prints the configuration that the server would return for the applications and profiles (comma-separated, as in
+/{application}/{profiles}), without running a server. Reads a directory, a Git repository, or the backends of a
+server's configuration file.`
+
+var renderFormats = map[string]func(io.Writer, api.ResolvedConfigValues) error{
+	"json":       writeJSON,
+	"yaml":       writeYAML,
+	"properties": writeProperties,
+	"dotenv":     writeDotenv,
+}
+
+// renderOptions are those shared with `lint`
+type renderOptions struct {
+	source        string
+	label         string
+	defaultBranch string
+	configFile    string
+	searchPaths   string
+}
+
+func (o *renderOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.source, "source", "", "directory, or Git URL, to read (default: the -config backends, else the current directory)")
+	flags.StringVar(&o.label, "label", "", "Git branch, tag or commit; a directory is then read as a Git repository")
+	flags.StringVar(&o.defaultBranch, "default-branch", "", "Git branch cloned, and read without a -label (default: as configured, else master)")
+	flags.StringVar(&o.configFile, "config", "", "server configuration to take defaults, search paths, credentials, templates and K8s settings from")
+	flags.StringVar(&o.searchPaths, "search-paths", "", "comma-separated Git search paths, e.g. {application},shared/*")
+}
+
+func renderCommand(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("render", renderUsage, stderr)
+
+	var opts renderOptions
+	opts.register(flags)
+	format := flags.String("format", "json", "json, yaml, properties or dotenv; the last two are always flattened")
+	flatten := flags.Bool("flatten", false, "flatten hierarchies into dotted keys (default: as configured)")
+	flattenLists := flags.Bool("flatten-lists", false, "flatten lists into indexed keys, e.g. hosts[0] (default: as configured)")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		flags.Usage()
+		return usageError{}
+	}
+
+	write, ok := renderFormats[*format]
+	if !ok {
+		return usageError{fmt.Errorf("unknown format [%s]", *format)}
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		return err
+	}
+
+	req := api.ConfigurationRequest{
+		Applications:          utils.SplitApplicationNames(positional[0]),
+		Profiles:              []string{"default"},
+		Labels:                api.LabelsRequest{Branch: opts.label},
+		RefreshBackend:        false,
+		FlattenHierarchies:    cfg.Defaults.FlattenHierarchicalConfig,
+		FlattenedIndexedLists: cfg.Defaults.FlattenedIndexedLists,
+	}
+	if len(positional) > 1 {
+		req.Profiles = utils.SplitProfileNames(positional[1])
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "flatten":
+			req.FlattenHierarchies = *flatten
+		case "flatten-lists":
+			req.FlattenedIndexedLists = *flattenLists
+		}
+	})
+	if *format == "properties" || *format == "dotenv" {
+		req.FlattenHierarchies = true
+		req.FlattenedIndexedLists = true
+	}
+
+	backends, err := opts.backends(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeAll(backends)
+
+	values, err := resolve(ctx, cfg, backends, req)
+	if err != nil {
+		return err
+	}
+	return write(stdout, values)
+}
+
+// The configuration file's, if any, though only its other settings apply if there's a `source`
+func (o *renderOptions) config() (config.ApplicationConfiguration, error) {
+	cfg := config.ApplicationConfiguration{}
+	if o.configFile != "" {
+		loaded, err := loadConfig(o.configFile, true)
+		if err != nil {
+			return cfg, err
+		}
+		cfg = loaded
+	}
+
+	if o.searchPaths != "" {
+		cfg.Git.SearchPaths = strings.Split(o.searchPaths, ",")
+	}
+	if o.defaultBranch != "" {
+		cfg.Git.DefaultBranchName = o.defaultBranch
+	}
+	return cfg, nil
+}
+
+// Git repositories are cloned into memory, so nothing is left behind
+func (o *renderOptions) backends(ctx context.Context, cfg config.ApplicationConfiguration) (backend.Backends, error) {
+	source := o.source
+	if source == "" && o.configFile != "" {
+		cfg.Git.InMemory = true
+		cfg.Git.CloneOnStart = true
+		cfg.Git.RefreshRateMillis = 0
+		return setup.Init(ctx, cfg)
+	}
+	if source == "" {
+		source = "."
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		if o.label == "" {
+			// Plain files are only read from the top level, so search paths would silently be ignored
+			if len(cfg.Git.SearchPaths) > 0 {
+				return nil, usageError{fmt.Errorf("search paths need a -label to read %s at, as its files are otherwise only read from the top level", source)}
+			}
+
+			b := &file.Backend{}
+			return backend.Backends{b}, b.Init(ctx, config.ApplicationConfiguration{File: config.FileConfig{Path: source}})
+		}
+
+		repo, e := goGit.PlainOpenWithOptions(source, &goGit.PlainOpenOptions{DetectDotGit: true})
+		if e != nil {
+			return nil, fmt.Errorf("%s is not a Git repository, so can't be read at a label: %w", source, e)
+		}
+		gitConfig := cfg.Git
+		gitConfig.Repos = nil
+		return backend.Backends{&git.Backend{Config: gitConfig, Repo: repo}}, nil
+	}
+
+	gitConfig := cfg.Git
+	gitConfig.Uri = source
+	gitConfig.Repos = nil
+	gitConfig.InMemory = true
+	gitConfig.CloneOnStart = true
+	gitConfig.RefreshRateMillis = 0
+
+	b := &git.Backend{}
+	if err := b.Init(ctx, config.ApplicationConfiguration{Git: gitConfig}); err != nil {
+		return nil, fmt.Errorf("cannot clone %s: %w", source, err)
+	}
+	return backend.Backends{b}, nil
+}
+
+// As the server would, though only resolving K8s placeholders if the configuration enables them
+func resolve(ctx context.Context, cfg config.ApplicationConfiguration, backends backend.Backends, req api.ConfigurationRequest) (api.ResolvedConfigValues, error) {
+	_, k8sResolver, err := setupK8s(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := api.LoadConfigurations(ctx, backends, req)
+	if err != nil {
+		return nil, err
+	}
+
+	values, _, err := api.NewResolver(req, cfg.Gotemplate, k8sResolver).ReconcileProperties(ctx, req.Applications, req.Profiles, api.InjectedProperties{}, source)
+	return values, err
+}
+
+func closeAll(backends backend.Backends) {
+	for _, each := range backends {
+		each.Close()
+	}
+}
+
+func writeJSON(w io.Writer, values api.ResolvedConfigValues) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+func writeYAML(w io.Writer, values api.ResolvedConfigValues) error {
+	bs, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bs)
+	return err
+}
+
+// As read by `java.util.Properties`
+func writeProperties(w io.Writer, values api.ResolvedConfigValues) error {
+	for _, key := range sortedKeys(values) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", escapeProperty(key, true), escapeProperty(stringValue(values[key]), false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, each := range s {
+		switch {
+		case each == '\\':
+			b.WriteString(`\\`)
+		case each == '\n':
+			b.WriteString(`\n`)
+		case each == '\r':
+			b.WriteString(`\r`)
+		case each == '\t':
+			b.WriteString(`\t`)
+		case each == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case isKey && (each == '=' || each == ':' || (each == '#' || each == '!') && i == 0):
+			b.WriteRune('\\')
+			b.WriteRune(each)
+		case each > 0x7e:
+			if r1, r2 := utf16.EncodeRune(each); r1 != unicode.ReplacementChar {
+				_, _ = fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			} else {
+				_, _ = fmt.Fprintf(&b, `\u%04x`, each)
+			}
+		default:
+			b.WriteRune(each)
+		}
+	}
+	return b.String()
+}
+
+// Keys as Spring Boot binds them from the environment, e.g. `hosts[0].url` as `HOSTS_0_URL`
+func writeDotenv(w io.Writer, values api.ResolvedConfigValues) error {
+	names := map[string]string{}
+	for _, key := range sortedKeys(values) {
+		name := dotenvName(key)
+		if previous, found := names[name]; found {
+			return fmt.Errorf("both [%s] and [%s] would be %s", previous, key, name)
+		}
+		names[name] = key
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", name, quoteDotenv(stringValue(values[key]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dotenvName(key string) string {
+	replacer := strings.NewReplacer(".", "_", "[", "_", "]", "", "-", "")
+	return strings.ToUpper(replacer.Replace(key))
+}
+
+// Single quotes are taken literally, so are used unless the value contains one or a line break
+func quoteDotenv(value string) string {
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func stringValue(v any) string {
+	switch typed := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case map[string]any, []any:
+		bs, _ := json.Marshal(typed)
+		return string(bs)
+	default:
+		return fmt.Sprintf("%v", typed)
+	}
+}
+
+func sortedKeys(values api.ResolvedConfigValues) []string {
+	keys := make([]string, 0, len(values))
+	for each := range values {
+		keys = append(keys, each)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	_writeFile(t, dir, "accounts.yml", "site:\n  url: https://${host}\n  name: \"Café #1\"\nhost: test.com\nhosts: [a, b]\n")
+	_writeFile(t, dir, "accounts-production.yml", "host: prod.com\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "json",
+			args:     []string{"accounts", "production", "-source", dir},
+			expected: "{\n  \"host\": \"prod.com\",\n  \"hosts\": [\n    \"a\",\n    \"b\"\n  ],\n  \"site\": {\n    \"name\": \"Café #1\",\n    \"url\": \"https://prod.com\"\n  }\n}\n",
+		},
+		{
+			name:     "default profile",
+			args:     []string{"-source", dir, "-flatten", "accounts"},
+			expected: "{\n  \"host\": \"test.com\",\n  \"hosts\": [\n    \"a\",\n    \"b\"\n  ],\n  \"site.name\": \"Café #1\",\n  \"site.url\": \"https://test.com\"\n}\n",
+		},
+		{
+			name:     "yaml",
+			args:     []string{"-source", dir, "-format", "yaml", "accounts", "production"},
+			expected: "host: prod.com\nhosts:\n- a\n- b\nsite:\n  name: 'Café #1'\n  url: https://prod.com\n",
+		},
+		{
+			name:     "properties",
+			args:     []string{"-source", dir, "-format", "properties", "accounts", "production"},
+			expected: "host=prod.com\nhosts[0]=a\nhosts[1]=b\nsite.name=Caf\\u00e9 #1\nsite.url=https://prod.com\n",
+		},
+		{
+			name:     "dotenv",
+			args:     []string{"-source", dir, "-format", "dotenv", "accounts", "production"},
+			expected: "HOST='prod.com'\nHOSTS_0='a'\nHOSTS_1='b'\nSITE_NAME='Café #1'\nSITE_URL='https://prod.com'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCommand(context.Background(), "render", tt.args, &stdout, &stderr)
+
+			assert.Equal(t, 0, code, stderr.String())
+			assert.Equal(t, tt.expected, stdout.String())
+		})
+	}
+}
+
+func TestRenderLabel(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := goGit.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_writeFile(t, dir, "accounts.yml", "host: committed.com\n")
+	_, err = wt.Add("accounts.yml")
+	require.NoError(t, err)
+	_, err = wt.Commit("init", &goGit.CommitOptions{Author: &object.Signature{Name: "a", Email: "a@b", When: time.Now()}})
+	require.NoError(t, err)
+
+	// Uncommitted
+	_writeFile(t, dir, "accounts.yml", "host: edited.com\n")
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand(context.Background(), "render", []string{"-source", dir, "-format", "properties", "accounts"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "host=edited.com\n", stdout.String())
+
+	stdout.Reset()
+	code = runCommand(context.Background(), "render", []string{"-source", dir, "-label", "master", "-format", "properties", "accounts"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "host=committed.com\n", stdout.String())
+}
+
+func TestRenderFailures(t *testing.T) {
+	dir := t.TempDir()
+	_writeFile(t, dir, "accounts.yml", "a: ${b}\nb: ${a}\n")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{
+			name:   "no application",
+			args:   []string{"-source", dir},
+			code:   2,
+			stderr: "Usage: gccs render",
+		},
+		{
+			name:   "unknown format",
+			args:   []string{"-source", dir, "-format", "toml", "accounts"},
+			code:   2,
+			stderr: "unknown format [toml]",
+		},
+		{
+			name:   "not a repository",
+			args:   []string{"-source", dir, "-label", "main", "accounts"},
+			code:   1,
+			stderr: "is not a Git repository",
+		},
+		{
+			name:   "search paths without label",
+			args:   []string{"-source", dir, "-search-paths", "{application}", "accounts"},
+			code:   2,
+			stderr: "search paths need a -label to read " + dir + " at",
+		},
+		{
+			name:   "unresolvable",
+			args:   []string{"-source", dir, "accounts"},
+			code:   1,
+			stderr: "stack overflow found when resolving",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.code, runCommand(context.Background(), "render", tt.args, &stdout, &stderr))
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, runCommand(context.Background(), "rendr", nil, &stdout, &stderr))
+	assert.Equal(t, "unknown command [rendr], expected one of: lint, render\n", stderr.String())
+}
+
+func TestEscaping(t *testing.T) {
+	assert.Equal(t, `a\=b\:c\ d`, escapeProperty("a=b:c d", true))
+	assert.Equal(t, `\#comment`, escapeProperty("#comment", true))
+	assert.Equal(t, `\ x=y #z\\\n\u00e9\ud83d\ude00`, escapeProperty(" x=y #z\\\né😀", false))
+
+	assert.Equal(t, "HOSTS_0_URL", dotenvName("hosts[0].url"))
+	assert.Equal(t, "SERVERPORT", dotenvName("server-port"))
+
+	assert.Equal(t, `'a $b "c"'`, quoteDotenv(`a $b "c"`))
+	assert.Equal(t, `"it's\n\$b \"c\""`, quoteDotenv("it's\n$b \"c\""))
+}
+
+func _writeFile(t *testing.T, dir string, name string, contents string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644))
+}
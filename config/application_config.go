@@ -2,6 +2,7 @@ package config
 
 type Configuration struct {
 	ApplicationConfigFileYmlPath string `env:"APP_CONFIG_FILE_YML_PATH" envDefault:"application.yml"`
+	StrictApplicationConfig      bool   `env:"APP_CONFIG_STRICT"` // refuse to start without the file, rather than configure from the environment
 }
 
 // ApplicationConfiguration Must use full names for `sigs.k8s.io/yaml`
@@ -14,6 +15,12 @@ type ApplicationConfiguration struct {
 	Defaults   Defaults
 	Tracing    Tracing
 	Gotemplate GoTemplate
+	Monitor    Monitor
+	Watch      Watch
+	Webhooks   Webhooks
+	Cache      Cache
+	Auth       Auth
+	Reload     Reload
 }
 
 type Defaults struct {
@@ -25,7 +32,82 @@ type Defaults struct {
 }
 
 type Server struct {
-	Port int
+	Port int // default 80, or 443 with TLS
+	Tls  Tls
+
+	ReadHeaderTimeoutMillis int64 `json:"readHeaderTimeout"` // default 10s
+	ReadTimeoutMillis       int64 `json:"readTimeout"`       // including the body (default 30s)
+	WriteTimeoutMillis      int64 `json:"writeTimeout"`      // default 60s; watches are exempt
+	IdleTimeoutMillis       int64 `json:"idleTimeout"`       // for keep-alive connections (default 2 mins)
+
+	ShutdownDelayMillis int64 `json:"shutdownDelay"` // how long readiness fails before we stop accepting requests (default 5s, or -1 for none)
+	ShutdownGraceMillis int64 `json:"shutdownGrace"` // how long in-flight requests then have to finish (default 20s)
+}
+
+func (s Server) Validate() Server {
+	if s.ReadHeaderTimeoutMillis <= 0 {
+		s.ReadHeaderTimeoutMillis = 10000
+	}
+	if s.ReadTimeoutMillis <= 0 {
+		s.ReadTimeoutMillis = 30000
+	}
+	if s.WriteTimeoutMillis <= 0 {
+		s.WriteTimeoutMillis = 60000
+	}
+	if s.IdleTimeoutMillis <= 0 {
+		s.IdleTimeoutMillis = 120000
+	}
+	if s.ShutdownDelayMillis < 0 {
+		s.ShutdownDelayMillis = 0
+	} else if s.ShutdownDelayMillis == 0 {
+		s.ShutdownDelayMillis = 5000
+	}
+	if s.ShutdownGraceMillis <= 0 {
+		s.ShutdownGraceMillis = 20000
+	}
+	return s
+}
+
+// Tls serves HTTPS, reloading the certificate, key and client CAs whenever their files change
+type Tls struct {
+	CertFile     string `json:"certFile"`     // PEM, including any intermediates
+	KeyFile      string `json:"keyFile"`      // PEM
+	ClientCaFile string `json:"clientCaFile"` // PEM CAs that client certificates are verified against
+	ClientAuth   string `json:"clientAuth"`   // with a client CA: `verifyIfGiven` (default) or `require`
+	MinVersion   string `json:"minVersion"`   // `1.2` (default) or `1.3`
+	ReloadMillis int64  `json:"reload"`       // how often the files are checked for changes (default 10s)
+}
+
+func (t Tls) Enabled() bool {
+	return t.CertFile != ""
+}
+
+func (t Tls) Validate() Tls {
+	if t.ClientAuth == "" && t.ClientCaFile != "" {
+		t.ClientAuth = "verifyIfGiven"
+	}
+	if t.MinVersion == "" {
+		t.MinVersion = "1.2"
+	}
+	if t.ReloadMillis <= 0 {
+		t.ReloadMillis = 10000
+	}
+	return t
+}
+
+// Reload applies changes to this configuration without a restart: whenever the file changes, on `SIGHUP`, or on
+// `POST /admin/reload`. Changes to `server` and `tracing`, and to `Watch` and `IntervalMillis`, still need a restart.
+type Reload struct {
+	Watch          bool  // check the file for changes
+	IntervalMillis int64 `json:"interval"` // how often (default 10s)
+	Endpoint       bool  // register `POST /admin/reload` for `admin` policies, if authentication is configured
+}
+
+func (r Reload) Validate() Reload {
+	if r.IntervalMillis <= 0 {
+		r.IntervalMillis = 10000
+	}
+	return r
 }
 
 type Tracing struct {
@@ -34,6 +116,12 @@ type Tracing struct {
 	SamplerFraction float64
 }
 
+// Monitor receives push webhooks at `/monitor`, to refresh the affected repository straight away
+type Monitor struct {
+	Enabled bool
+	Secret  string // verifies GitHub, Gitea and Bitbucket signatures, and GitLab's token; if blank, nothing is verified, which is only allowed without authentication
+}
+
 type Prometheus struct {
 	Path string
 }
@@ -52,3 +140,81 @@ func (t GoTemplate) Validate() GoTemplate {
 	}
 	return t
 }
+
+// Cache holds parsed files by blob hash, and resolved outputs by backend version and request
+type Cache struct {
+	Disabled     bool
+	MaxFiles     int   `json:"maxFiles"`   // parsed files kept, per Git repository (default 10,000)
+	MaxOutputs   int   `json:"maxOutputs"` // resolved outputs kept (default 1,000)
+	K8sTtlMillis int64 `json:"k8sTtl"`     // outputs using K8s values are resolved again after this (default 30s)
+}
+
+func (c Cache) Validate() Cache {
+	if c.MaxFiles <= 0 {
+		c.MaxFiles = 10000
+	}
+	if c.MaxOutputs <= 0 {
+		c.MaxOutputs = 1000
+	}
+	if c.K8sTtlMillis <= 0 {
+		c.K8sTtlMillis = 30000
+	}
+	return c
+}
+
+// Watch governs `/{application}/{profiles}/watch` streams and long-polls
+type Watch struct {
+	PollIntervalMillis int64 `json:"pollInterval"` // how often each watched configuration is resolved again (default 5s)
+	MaxWaitMillis      int64 `json:"maxWait"`      // long-polls return `304 Not Modified` after this (default 30s)
+	HeartbeatMillis    int64 `json:"heartbeat"`    // keep-alive comments on idle streams (default 15s)
+}
+
+func (w Watch) Validate() Watch {
+	if w.PollIntervalMillis <= 0 {
+		w.PollIntervalMillis = 5000
+	}
+	if w.MaxWaitMillis <= 0 {
+		w.MaxWaitMillis = 30000
+	}
+	if w.HeartbeatMillis <= 0 {
+		w.HeartbeatMillis = 15000
+	}
+	return w
+}
+
+// Webhooks are sent a signed event for each change picked up by a backend, e.g. to refresh clients
+type Webhooks struct {
+	Endpoints []WebhookEndpoint
+
+	TimeoutMillis        int64 `json:"timeout"`        // per attempt (default 10s)
+	MaxAttempts          int   `json:"maxAttempts"`    // default 5
+	InitialBackoffMillis int64 `json:"initialBackoff"` // doubling each attempt (default 1s)...
+	MaxBackoffMillis     int64 `json:"maxBackoff"`     // ... up to this (default 1 min)
+	LogSize              int   `json:"logSize"`        // recent deliveries kept for `/webhooks/deliveries` (default 100)
+}
+
+type WebhookEndpoint struct {
+	Name         string
+	Url          string
+	Secret       string   // signs each event, as `X-Gccs-Signature: sha256=...`
+	Applications []string // only changes affecting these (globs) are sent, default all
+}
+
+func (w Webhooks) Validate() Webhooks {
+	if w.TimeoutMillis <= 0 {
+		w.TimeoutMillis = 10000
+	}
+	if w.MaxAttempts <= 0 {
+		w.MaxAttempts = 5
+	}
+	if w.InitialBackoffMillis <= 0 {
+		w.InitialBackoffMillis = 1000
+	}
+	if w.MaxBackoffMillis <= 0 {
+		w.MaxBackoffMillis = 60000
+	}
+	if w.LogSize <= 0 {
+		w.LogSize = 100
+	}
+	return w
+}
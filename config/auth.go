@@ -0,0 +1,76 @@
+package config
+
+// Auth protects the configuration endpoints. Liveness, readiness, metrics and `/monitor` (which verifies its own
+// signatures) are always open. With nothing configured, everything is open.
+type Auth struct {
+	Basic      BasicAuth
+	Bearer     BearerAuth
+	Jwt        JwtAuth
+	Kubernetes KubernetesAuth
+
+	ClientCertificates bool `json:"clientCertificates"` // accept TLS client certificates verified against `server.tls.clientCaFile`
+
+	PoliciesFile         string `json:"policiesFile"`   // YAML, limiting what each principal may read, and who may use admin endpoints
+	PoliciesReloadMillis int64  `json:"policiesReload"` // how often the file is checked for changes (default 10s)
+}
+
+func (a Auth) Validate() Auth {
+	if a.PoliciesReloadMillis <= 0 {
+		a.PoliciesReloadMillis = 10000
+	}
+	a.Kubernetes = a.Kubernetes.Validate()
+	return a
+}
+
+func (a Auth) Enabled() bool {
+	return len(a.Basic.Users) > 0 || len(a.Bearer.Tokens) > 0 || a.Jwt.Enabled() || a.Kubernetes.Enabled || a.ClientCertificates
+}
+
+type BasicAuth struct {
+	Users map[string]string // username to bcrypt hash, e.g. from `htpasswd -nbBC 10 <user> <password>`
+	Realm string            // default "gccs"
+}
+
+type BearerAuth struct {
+	Tokens map[string]string // principal name to static token
+}
+
+// JwtAuth accepts signed (not encrypted) JWTs, which must carry an expiry
+type JwtAuth struct {
+	JwksFile       string   `json:"jwksFile"`
+	PublicKeyFiles []string `json:"publicKeyFiles"` // PEM, as an alternative or in addition to a JWKS
+	Issuer         string   // `iss` must match, if set
+	Audience       string   // `aud` must include this, if set
+	LeewayMillis   int64    `json:"leeway"`       // clock skew allowed for `exp`, `nbf` and `iat` (default 1 min)
+	SubjectClaim   string   `json:"subjectClaim"` // identifies the principal (default `sub`)
+}
+
+func (j JwtAuth) Enabled() bool {
+	return j.JwksFile != "" || len(j.PublicKeyFiles) > 0
+}
+
+func (j JwtAuth) Validate() JwtAuth {
+	if j.LeewayMillis <= 0 {
+		j.LeewayMillis = 60000
+	}
+	if j.SubjectClaim == "" {
+		j.SubjectClaim = "sub"
+	}
+	return j
+}
+
+// KubernetesAuth accepts ServiceAccount tokens, confirmed with the TokenReview API using the `kubernetes` client
+// settings. Callers are named `<namespace>/<service account>`.
+type KubernetesAuth struct {
+	Enabled         bool
+	Audiences       []string            // the token must have been issued for one of these, if set
+	ServiceAccounts map[string][]string `json:"serviceAccounts"` // `namespace/name` globs to the applications each may read; others are rejected
+	CacheMillis     int64               `json:"cache"`           // how long a confirmed token is trusted before being reviewed again (default 1 min)
+}
+
+func (k KubernetesAuth) Validate() KubernetesAuth {
+	if k.CacheMillis <= 0 {
+		k.CacheMillis = 60000
+	}
+	return k
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Override sets any field named by an environment variable, e.g. `git.privateKey` by `GIT_PRIVATE_KEY`, or by the
+// contents of the file named by `GIT_PRIVATE_KEY_FILE`. Lists may be comma-separated, and anything else not a string
+// is parsed as YAML, e.g. `GIT_REPOS='[{name: payments, pattern: ["payments*"], uri: ...}]'`. Returns the variables
+// used.
+func (c *ApplicationConfiguration) Override(lookup func(string) (string, bool)) ([]string, error) {
+	o := overrider{lookup: lookup}
+	o.overrideStruct(reflect.ValueOf(c).Elem(), "")
+	return o.applied, errors.Join(o.errs...)
+}
+
+type overrider struct {
+	lookup  func(string) (string, bool)
+	applied []string
+	errs    []error
+}
+
+func (o *overrider) overrideStruct(v reflect.Value, prefix string) {
+	names := map[string]bool{}
+	for _, each := range reflect.VisibleFields(v.Type()) {
+		names[prefix+envName(each)] = true
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + envName(field)
+		if field.Type.Kind() == reflect.Struct {
+			o.overrideStruct(v.Field(i), name+"_")
+			continue
+		}
+
+		// e.g. `GIT_PRIVATE_KEY_FILE` is `git.privateKeyFile`
+		withFile := !names[name+"_FILE"]
+
+		value, from, err := o.value(name, withFile)
+		if err != nil {
+			o.errs = append(o.errs, err)
+			continue
+		}
+		if from == "" {
+			continue
+		}
+
+		if e := set(v.Field(i), value); e != nil {
+			o.errs = append(o.errs, fmt.Errorf("%s: %w", from, e))
+			continue
+		}
+		o.applied = append(o.applied, from)
+	}
+}
+
+// The variable itself, else the contents of the file its `_FILE` variant names, unless that's a field of its own
+func (o *overrider) value(name string, withFile bool) (string, string, error) {
+	value, found := o.lookup(name)
+
+	var file string
+	fromFile := false
+	if withFile {
+		file, fromFile = o.lookup(name + "_FILE")
+	}
+
+	switch {
+	case found && fromFile:
+		return "", "", fmt.Errorf("only one of %s and %s_FILE may be set", name, name)
+	case found:
+		return value, name, nil
+	case fromFile:
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(bs), "\r\n"), name + "_FILE", nil
+	default:
+		return "", "", nil
+	}
+}
+
+// Replaces, rather than merges with, any list or map from the file
+func set(field reflect.Value, value string) error {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+
+	if field.Type() == reflect.TypeOf([]string{}) && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var values []string
+		for _, each := range strings.Split(value, ",") {
+			if each = strings.TrimSpace(each); each != "" {
+				values = append(values, each)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+
+	parsed := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("expected %s: %w", field.Type(), err)
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
+
+// envName derives a field's variable name, in upper snake case, from the name it has in YAML
+func envName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		name = field.Name
+	}
+
+	runes := []rune(name)
+	var b strings.Builder
+	for i, each := range runes {
+		if each == '-' {
+			b.WriteRune('_')
+			continue
+		}
+
+		// Starting a word, including after an acronym, e.g. `CacheTTLSeconds`
+		if i > 0 && unicode.IsUpper(each) {
+			previous := runes[i-1]
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(each))
+	}
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverride(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cret\n"), 0o600))
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected func(c *ApplicationConfiguration)
+		applied  []string
+		err      string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "scalars",
+			env: map[string]string{
+				"SERVER_PORT":              "8888",
+				"SERVER_TLS_CERT_FILE":     "/tls/tls.crt",
+				"GIT_URI":                  "git@github.com:Org/config.git",
+				"GIT_REFRESH_RATE":         "5000",
+				"GIT_CLONE_ON_START":       "true",
+				"TRACING_SAMPLER_FRACTION": "0.5",
+			},
+			expected: func(c *ApplicationConfiguration) {
+				c.Server.Port = 8888
+				c.Server.Tls.CertFile = "/tls/tls.crt"
+				c.Git.Uri = "git@github.com:Org/config.git"
+				c.Git.RefreshRateMillis = 5000
+				c.Git.CloneOnStart = true
+				c.Tracing.SamplerFraction = 0.5
+			},
+			applied: []string{"SERVER_PORT", "SERVER_TLS_CERT_FILE", "GIT_URI", "GIT_CLONE_ON_START", "GIT_REFRESH_RATE", "TRACING_SAMPLER_FRACTION"},
+		},
+		{
+			name: "from a file",
+			env:  map[string]string{"GIT_PASSPHRASE_FILE": secretFile},
+			expected: func(c *ApplicationConfiguration) {
+				c.Git.Passphrase = "s3cret"
+			},
+			applied: []string{"GIT_PASSPHRASE_FILE"},
+		},
+		{
+			name: "a field of its own",
+			env:  map[string]string{"GIT_PRIVATE_KEY_FILE": secretFile},
+			expected: func(c *ApplicationConfiguration) {
+				c.Git.PrivateKeyFile = secretFile
+			},
+			applied: []string{"GIT_PRIVATE_KEY_FILE"},
+		},
+		{
+			name: "lists and maps",
+			env: map[string]string{
+				"GIT_SEARCH_PATHS":             "{application}, shared/*",
+				"AUTH_JWT_PUBLIC_KEY_FILES":    `["/keys/a.pem", "/keys/b.pem"]`,
+				"AUTH_BEARER_TOKENS":           "{ci: secret}",
+				"KUBERNETES_CACHE_TTL_SECONDS": "30",
+				"GIT_REPOS":                    `[{name: payments, pattern: ["payments*"], uri: "git@github.com:Org/payments.git"}]`,
+			},
+			expected: func(c *ApplicationConfiguration) {
+				c.Git.SearchPaths = []string{"{application}", "shared/*"}
+				c.Git.Repos = []GitRepoConfig{{Name: "payments", Pattern: []string{"payments*"}, Uri: "git@github.com:Org/payments.git"}}
+				c.Kubernetes.CacheTTLSeconds = 30
+				c.Auth.Bearer.Tokens = map[string]string{"ci": "secret"}
+				c.Auth.Jwt.PublicKeyFiles = []string{"/keys/a.pem", "/keys/b.pem"}
+			},
+			applied: []string{"GIT_SEARCH_PATHS", "GIT_REPOS", "KUBERNETES_CACHE_TTL_SECONDS", "AUTH_BEARER_TOKENS", "AUTH_JWT_PUBLIC_KEY_FILES"},
+		},
+		{
+			name: "unparseable",
+			env:  map[string]string{"SERVER_PORT": "http"},
+			err:  "SERVER_PORT: expected int",
+		},
+		{
+			name: "both",
+			env:  map[string]string{"GIT_TOKEN": "a", "GIT_TOKEN_FILE": secretFile},
+			err:  "only one of GIT_TOKEN and GIT_TOKEN_FILE may be set",
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{"GIT_TOKEN_FILE": "/missing"},
+			err:  "GIT_TOKEN_FILE: open /missing: no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ApplicationConfiguration{Git: GitConfig{Uri: "from the file", SearchPaths: []string{"config"}}}
+
+			applied, err := cfg.Override(func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			})
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			expected := ApplicationConfiguration{Git: GitConfig{Uri: "from the file", SearchPaths: []string{"config"}}}
+			if tt.expected != nil {
+				tt.expected(&expected)
+			}
+			assert.Equal(t, expected, cfg)
+			assert.Equal(t, tt.applied, applied)
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	names := map[string]string{}
+	for _, each := range reflect.VisibleFields(reflect.TypeOf(Server{})) {
+		names[each.Name] = envName(each)
+	}
+	assert.Equal(t, "PORT", names["Port"])
+	assert.Equal(t, "TLS", names["Tls"])
+	assert.Equal(t, "READ_HEADER_TIMEOUT", names["ReadHeaderTimeoutMillis"])
+
+	field, _ := reflect.TypeOf(K8sConfig{}).FieldByName("CacheTTLSeconds")
+	assert.Equal(t, "CACHE_TTL_SECONDS", envName(field))
+
+	field, _ = reflect.TypeOf(GitConfig{}).FieldByName("CloneOnStart")
+	assert.Equal(t, "CLONE_ON_START", envName(field))
+
+	field, _ = reflect.TypeOf(Defaults{}).FieldByName("PrettyPrintJson")
+	assert.Equal(t, "PRETTY_PRINT_JSON", envName(field))
+}
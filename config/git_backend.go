@@ -1,16 +1,39 @@
 package config
 
+import (
+	"net/url"
+	"strings"
+)
+
 type GitConfig struct {
 	Disabled bool
 	Order    int
 
-	Uri            string
-	KnownHostsFile string `json:"knownHostsFile"`
-	PrivateKey     string `json:"privateKey"`
+	Uri string
+
+	// SSH
+	KnownHostsFile                  string `json:"knownHostsFile"`
+	PrivateKey                      string `json:"privateKey"`
+	PrivateKeyFile                  string `json:"privateKeyFile"`
+	Passphrase                      string `json:"passphrase"`
+	InsecureSkipHostKeyVerification bool   `json:"insecureSkipHostKeyVerification"` // development only!
+
+	// HTTPS. Env vars GIT_USERNAME, GIT_PASSWORD, GIT_TOKEN and GIT_PASSPHRASE take precedence
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	Token           string `json:"token"`           // e.g. a personal access token, sent as the password
+	CredentialsFile string `json:"credentialsFile"` // `username:password`, a bare token, or a git-credentials URL
 
 	Basedir                string `json:"basedir"`
 	DisableBaseDirCleaning bool   `json:"disableBaseDirCleaning"`
 
+	// Clone into memory rather than `Basedir`, e.g. for a read-only root filesystem
+	InMemory         bool  `json:"inMemory"`
+	MaxInMemoryBytes int64 `json:"maxInMemoryBytes"` // refuse repositories larger than this (default 256 MiB)
+
+	// Directories searched as well as the root, most specific first, e.g. `{application}`, `shared/*`, `config/{profile}`
+	SearchPaths []string `json:"searchPaths"`
+
 	DisableLabels     bool   `json:"disableLabels"`
 	DefaultBranchName string `json:"defaultBranchName"`
 
@@ -19,6 +42,132 @@ type GitConfig struct {
 
 	ShowProgress bool `json:"show-progress"`
 
-	TimeoutMillis     int64 `json:"timeout"`
-	RefreshRateMillis int64 `json:"refreshRate"`
+	TimeoutMillis     int64          `json:"timeout"` // per clone, pull or fetch attempt (0 = no deadline)
+	RefreshRateMillis int64          `json:"refreshRate"`
+	Retry             GitRetryConfig `json:"retry"`
+
+	// Keep serving the last fetched commit for up to this long if a refresh fails, whether on request or when polling
+	// (0 = fail the request)
+	MaxStalenessMillis int64 `json:"maxStaleness"`
+
+	// Readiness fails once either threshold is exceeded (0 = not checked)
+	MaxRefreshAgeMillis int64 `json:"maxRefreshAge"`
+	MaxCommitAgeMillis  int64 `json:"maxCommitAge"`
+
+	// Further repositories, each serving the applications / profiles matching its patterns, else the above is used
+	Repos []GitRepoConfig `json:"repos"`
+}
+
+// GitRepoConfig Any other settings (timeouts, refresh etc.) are as per the parent `GitConfig`
+type GitRepoConfig struct {
+	Name    string   `json:"name"`
+	Pattern []string `json:"pattern"` // `{application}/{profile}` globs, e.g. `payments*/*`; no `/` means any profile
+
+	Uri         string   `json:"uri"`
+	Basedir     string   `json:"basedir"` // defaults to `{parent basedir}-{name}`
+	SearchPaths []string `json:"searchPaths"`
+
+	// Credentials, if different to the parent's
+	KnownHostsFile  string `json:"knownHostsFile"`
+	PrivateKey      string `json:"privateKey"`
+	PrivateKeyFile  string `json:"privateKeyFile"`
+	Passphrase      string `json:"passphrase"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	Token           string `json:"token"`
+	CredentialsFile string `json:"credentialsFile"`
+}
+
+// DefaultBranch is the label served when a request names none
+func (g GitConfig) DefaultBranch() string {
+	if g.DefaultBranchName != "" {
+		return g.DefaultBranchName
+	}
+	return "master"
+}
+
+// ForRepo The configuration for one of our `Repos`
+func (g GitConfig) ForRepo(r GitRepoConfig) GitConfig {
+	derived := g
+	derived.Repos = nil
+
+	derived.Uri = r.Uri
+	derived.SearchPaths = r.SearchPaths
+
+	derived.Basedir = r.Basedir
+	if derived.Basedir == "" && g.Basedir != "" {
+		derived.Basedir = g.Basedir + "-" + r.Name
+	}
+
+	if r.KnownHostsFile != "" {
+		derived.KnownHostsFile = r.KnownHostsFile
+	}
+	// Our credentials are only for our own host, never to be sent to another
+	if r.hasCredentials() || uriHost(r.Uri) != uriHost(g.Uri) {
+		derived.PrivateKey = r.PrivateKey
+		derived.PrivateKeyFile = r.PrivateKeyFile
+		derived.Passphrase = r.Passphrase
+		derived.Username = r.Username
+		derived.Password = r.Password
+		derived.Token = r.Token
+		derived.CredentialsFile = r.CredentialsFile
+	}
+
+	return derived
+}
+
+// The host of `https://user@Host:443/org/repo.git`, `ssh://git@host/org/repo` or `git@host:org/repo.git`, including
+// any port, and empty for local paths
+func uriHost(uri string) string {
+	uri = strings.TrimSpace(uri)
+
+	if strings.Contains(uri, "://") {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "file" {
+			return ""
+		}
+		return strings.ToLower(u.Host)
+	}
+
+	if host, _, found := strings.Cut(uri, ":"); found && !strings.Contains(host, "/") {
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		return strings.ToLower(host)
+	}
+	return ""
+}
+
+func (r GitRepoConfig) hasCredentials() bool {
+	return r.PrivateKey != "" || r.PrivateKeyFile != "" || r.Username != "" || r.Password != "" || r.Token != "" || r.CredentialsFile != ""
+}
+
+// GitRetryConfig bounds how hard we try the remote on each clone, pull or fetch
+type GitRetryConfig struct {
+	MaxAttempts          int   `json:"maxAttempts"` // 1 = no retries
+	InitialBackoffMillis int64 `json:"initialBackoff"`
+	MaxBackoffMillis     int64 `json:"maxBackoff"`
+
+	DisableCircuitBreaker     bool  `json:"disableCircuitBreaker"`
+	CircuitBreakerThreshold   int   `json:"circuitBreakerThreshold"` // consecutive failures before we stop trying
+	CircuitBreakerResetMillis int64 `json:"circuitBreakerReset"`     // how long until we try again
+}
+
+func (r GitRetryConfig) Validate() GitRetryConfig {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = 3
+	}
+	if r.InitialBackoffMillis <= 0 {
+		r.InitialBackoffMillis = 200
+	}
+	if r.MaxBackoffMillis <= 0 {
+		r.MaxBackoffMillis = 5000
+	}
+	if r.CircuitBreakerThreshold <= 0 {
+		r.CircuitBreakerThreshold = 5
+	}
+	if r.CircuitBreakerResetMillis <= 0 {
+		r.CircuitBreakerResetMillis = 30000
+	}
+	return r
 }
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForRepo(t *testing.T) {
+	tests := []struct {
+		name     string
+		parent   GitConfig
+		repo     GitRepoConfig
+		expected GitConfig
+	}{
+		{
+			name:     "same host over https inherits credentials",
+			parent:   GitConfig{Uri: "https://github.com/Org/main.git", Username: "u", Password: "p"},
+			repo:     GitRepoConfig{Name: "x", Uri: "https://GitHub.com/Org/x.git"},
+			expected: GitConfig{Uri: "https://GitHub.com/Org/x.git", Username: "u", Password: "p"},
+		},
+		{
+			name:     "same host over ssh inherits credentials",
+			parent:   GitConfig{Uri: "git@github.com:Org/main.git", PrivateKeyFile: "/keys/id", Passphrase: "pp"},
+			repo:     GitRepoConfig{Name: "x", Uri: "ssh://git@github.com/Org/x.git"},
+			expected: GitConfig{Uri: "ssh://git@github.com/Org/x.git", PrivateKeyFile: "/keys/id", Passphrase: "pp"},
+		},
+		{
+			name:     "another host doesn't",
+			parent:   GitConfig{Uri: "https://github.com/Org/main.git", Username: "u", Password: "p", Token: "t", KnownHostsFile: "/known"},
+			repo:     GitRepoConfig{Name: "x", Uri: "https://gitlab.example.com/org/x.git"},
+			expected: GitConfig{Uri: "https://gitlab.example.com/org/x.git", KnownHostsFile: "/known"},
+		},
+		{
+			name:     "another port doesn't",
+			parent:   GitConfig{Uri: "https://git.example.com/org/main.git", CredentialsFile: "/creds"},
+			repo:     GitRepoConfig{Name: "x", Uri: "https://git.example.com:8443/org/x.git"},
+			expected: GitConfig{Uri: "https://git.example.com:8443/org/x.git"},
+		},
+		{
+			name:     "own credentials win",
+			parent:   GitConfig{Uri: "https://github.com/Org/main.git", Username: "u", Password: "p"},
+			repo:     GitRepoConfig{Name: "x", Uri: "https://gitlab.example.com/org/x.git", Token: "glpat"},
+			expected: GitConfig{Uri: "https://gitlab.example.com/org/x.git", Token: "glpat"},
+		},
+		{
+			name:     "basedir derived from the parent's",
+			parent:   GitConfig{Uri: "/srv/main", Basedir: "/tmp/config"},
+			repo:     GitRepoConfig{Name: "x", Uri: "/srv/x"},
+			expected: GitConfig{Uri: "/srv/x", Basedir: "/tmp/config-x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.parent.ForRepo(tt.repo))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Validate reports every misconfiguration we can detect without connecting to anything, each naming the setting at
+// fault. Unlike the defaulting `Validate` of each part, it changes nothing.
+func (c ApplicationConfiguration) Validate() error {
+	var errs []error
+	errs = append(errs, c.Server.problems()...)
+	errs = append(errs, c.Git.problems()...)
+	errs = append(errs, c.File.problems()...)
+	errs = append(errs, c.Kubernetes.problems()...)
+	errs = append(errs, c.Tracing.problems()...)
+	errs = append(errs, c.Webhooks.problems()...)
+	errs = append(errs, c.Auth.problems(c.Server.Tls)...)
+	errs = append(errs, c.Monitor.problems(c.Auth)...)
+	return errors.Join(errs...)
+}
+
+func (s Server) problems() []error {
+	var errs []error
+	if s.Port < 0 || s.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port [%d] is not a valid port", s.Port))
+	}
+
+	t := s.Tls
+	if !t.Enabled() {
+		if t.KeyFile != "" || t.ClientCaFile != "" {
+			errs = append(errs, errors.New("server.tls requires a certFile"))
+		}
+		return errs
+	}
+
+	if t.KeyFile == "" {
+		errs = append(errs, errors.New("server.tls.certFile requires a keyFile"))
+	}
+	switch t.ClientAuth {
+	case "", "verifyIfGiven", "require":
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.clientAuth [%s] must be `verifyIfGiven` or `require`", t.ClientAuth))
+	}
+	if t.ClientAuth != "" && t.ClientCaFile == "" {
+		errs = append(errs, errors.New("server.tls.clientAuth requires a clientCaFile"))
+	}
+	switch t.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.minVersion [%s] must be `1.2` or `1.3`", t.MinVersion))
+	}
+	return errs
+}
+
+func (g GitConfig) problems() []error {
+	if g.Disabled {
+		return nil
+	}
+
+	var errs []error
+	if g.Uri == "" {
+		errs = append(errs, errors.New("git.uri is required, unless the Git backend is disabled"))
+	}
+	if g.Basedir == "" && !g.InMemory {
+		errs = append(errs, errors.New("git.basedir is required, unless cloning inMemory"))
+	}
+	if g.PrivateKey != "" && g.PrivateKeyFile != "" {
+		errs = append(errs, errors.New("git: only one of privateKey and privateKeyFile may be set"))
+	}
+
+	names := map[string]bool{}
+	for i, each := range g.Repos {
+		switch {
+		case each.Name == "":
+			errs = append(errs, fmt.Errorf("git.repos[%d] requires a name", i))
+		case names[each.Name]:
+			errs = append(errs, fmt.Errorf("git.repos[%d]: duplicate name [%s]", i, each.Name))
+		}
+		names[each.Name] = true
+
+		if each.Uri == "" {
+			errs = append(errs, fmt.Errorf("git.repos[%d] requires a uri", i))
+		}
+		if len(each.Pattern) == 0 {
+			errs = append(errs, fmt.Errorf("git.repos[%d] requires a pattern", i))
+		}
+	}
+	return errs
+}
+
+func (f FileConfig) problems() []error {
+	if f.Disabled {
+		return nil
+	}
+	if f.Path == "" {
+		return []error{errors.New("file.path is required, unless the File backend is disabled")}
+	}
+	return nil
+}
+
+func (k K8sConfig) problems() []error {
+	if k.CacheTTLSeconds < 0 {
+		return []error{fmt.Errorf("kubernetes.cacheTTLSeconds [%d] may not be negative", k.CacheTTLSeconds)}
+	}
+	return nil
+}
+
+func (t Tracing) problems() []error {
+	if !t.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if t.Endpoint == "" {
+		errs = append(errs, errors.New("tracing.endpoint is required when tracing is enabled"))
+	}
+	if t.SamplerFraction < 0 || t.SamplerFraction > 1 {
+		errs = append(errs, fmt.Errorf("tracing.samplerFraction [%v] must be between 0 and 1", t.SamplerFraction))
+	}
+	return errs
+}
+
+func (w Webhooks) problems() []error {
+	var errs []error
+	for i, each := range w.Endpoints {
+		if u, err := url.Parse(each.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].url [%s] must be an http(s) URL", i, each.Url))
+		}
+	}
+	return errs
+}
+
+func (a Auth) problems(tls Tls) []error {
+	var errs []error
+	if a.PoliciesFile != "" && !a.Enabled() {
+		errs = append(errs, errors.New("auth.policiesFile requires authentication to be configured"))
+	}
+	if a.Kubernetes.Enabled && len(a.Kubernetes.ServiceAccounts) == 0 {
+		errs = append(errs, errors.New(`auth.kubernetes requires serviceAccounts, e.g. "*/*": [] to accept any`))
+	}
+	if a.ClientCertificates && tls.ClientCaFile == "" {
+		errs = append(errs, errors.New("auth.clientCertificates requires server.tls.clientCaFile"))
+	}
+	return errs
+}
+
+// Anyone could otherwise trigger refreshes on a server that's meant to be protected
+func (m Monitor) problems(auth Auth) []error {
+	if m.Enabled && m.Secret == "" && auth.Enabled() {
+		return []error{errors.New("monitor.secret is required when authentication is configured")}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := ApplicationConfiguration{
+		Git:  GitConfig{Uri: "git@github.com:Org/config.git", Basedir: "/tmp/cloud-config"},
+		File: FileConfig{Path: "/config-dir"},
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *ApplicationConfiguration)
+		expected []string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *ApplicationConfiguration) {},
+		},
+		{
+			name: "backends disabled",
+			modify: func(c *ApplicationConfiguration) {
+				c.Git = GitConfig{Disabled: true}
+				c.File = FileConfig{Disabled: true}
+			},
+		},
+		{
+			name: "git uri without basedir",
+			modify: func(c *ApplicationConfiguration) {
+				c.Git.Basedir = ""
+			},
+			expected: []string{"git.basedir is required, unless cloning inMemory"},
+		},
+		{
+			name: "git in memory",
+			modify: func(c *ApplicationConfiguration) {
+				c.Git.Basedir = ""
+				c.Git.InMemory = true
+			},
+		},
+		{
+			name: "git repos",
+			modify: func(c *ApplicationConfiguration) {
+				c.Git.Uri = ""
+				c.Git.Repos = []GitRepoConfig{
+					{Name: "payments", Pattern: []string{"payments*"}, Uri: "git@github.com:Org/payments.git"},
+					{Name: "payments", Pattern: []string{"ledger*"}},
+				}
+			},
+			expected: []string{
+				"git.uri is required, unless the Git backend is disabled",
+				"git.repos[1]: duplicate name [payments]",
+				"git.repos[1] requires a uri",
+			},
+		},
+		{
+			name: "file without path",
+			modify: func(c *ApplicationConfiguration) {
+				c.File.Path = ""
+			},
+			expected: []string{"file.path is required, unless the File backend is disabled"},
+		},
+		{
+			name: "tls",
+			modify: func(c *ApplicationConfiguration) {
+				c.Server.Tls = Tls{CertFile: "tls.crt", ClientAuth: "always", MinVersion: "1.1"}
+			},
+			expected: []string{
+				"server.tls.certFile requires a keyFile",
+				"server.tls.clientAuth [always] must be `verifyIfGiven` or `require`",
+				"server.tls.clientAuth requires a clientCaFile",
+				"server.tls.minVersion [1.1] must be `1.2` or `1.3`",
+			},
+		},
+		{
+			name: "tracing",
+			modify: func(c *ApplicationConfiguration) {
+				c.Tracing = Tracing{Enabled: true, SamplerFraction: 2}
+			},
+			expected: []string{
+				"tracing.endpoint is required when tracing is enabled",
+				"tracing.samplerFraction [2] must be between 0 and 1",
+			},
+		},
+		{
+			name: "webhooks",
+			modify: func(c *ApplicationConfiguration) {
+				c.Webhooks.Endpoints = []WebhookEndpoint{{Url: "https://hooks.example.com/gccs"}, {Url: "hooks.example.com"}}
+			},
+			expected: []string{"webhooks.endpoints[1].url [hooks.example.com] must be an http(s) URL"},
+		},
+		{
+			name: "auth",
+			modify: func(c *ApplicationConfiguration) {
+				c.Auth = Auth{PoliciesFile: "/policies.yml"}
+				c.Server.Port = 70000
+			},
+			expected: []string{
+				"server.port [70000] is not a valid port",
+				"auth.policiesFile requires authentication to be configured",
+			},
+		},
+		{
+			name: "client certificates",
+			modify: func(c *ApplicationConfiguration) {
+				c.Auth = Auth{ClientCertificates: true}
+			},
+			expected: []string{"auth.clientCertificates requires server.tls.clientCaFile"},
+		},
+		{
+			name: "kubernetes without service accounts",
+			modify: func(c *ApplicationConfiguration) {
+				c.Auth = Auth{Kubernetes: KubernetesAuth{Enabled: true}}
+			},
+			expected: []string{`auth.kubernetes requires serviceAccounts, e.g. "*/*": [] to accept any`},
+		},
+		{
+			name: "kubernetes for any service account",
+			modify: func(c *ApplicationConfiguration) {
+				c.Auth = Auth{Kubernetes: KubernetesAuth{Enabled: true, ServiceAccounts: map[string][]string{"*/*": nil}}}
+			},
+		},
+		{
+			name: "monitor without secret",
+			modify: func(c *ApplicationConfiguration) {
+				c.Monitor = Monitor{Enabled: true}
+			},
+		},
+		{
+			name: "monitor without secret, with authentication",
+			modify: func(c *ApplicationConfiguration) {
+				c.Monitor = Monitor{Enabled: true}
+				c.Auth = Auth{Bearer: BearerAuth{Tokens: map[string]string{"ci": "s3cret"}}}
+			},
+			expected: []string{"monitor.secret is required when authentication is configured"},
+		},
+		{
+			name: "monitor with secret, with authentication",
+			modify: func(c *ApplicationConfiguration) {
+				c.Monitor = Monitor{Enabled: true, Secret: "hook"}
+				c.Auth = Auth{Bearer: BearerAuth{Tokens: map[string]string{"ci": "s3cret"}}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var messages []string
+			for _, each := range err.(interface{ Unwrap() []error }).Unwrap() {
+				messages = append(messages, each.Error())
+			}
+			assert.Equal(t, tt.expected, messages)
+		})
+	}
+}
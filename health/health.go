@@ -1,8 +1,12 @@
 package health
 
 import (
-	"github.com/heptiolabs/healthcheck"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"sync"
+
+	"github.com/heptiolabs/healthcheck"
 )
 
 // New - A liveness check indicates that this instance of the application should be destroyed and replaced. A failed liveness check
@@ -14,24 +18,117 @@ import (
 // Liveness and Readiness endpoints will return 200 / OK out of the box to represent broad application health
 // They will start to return 5xx only if new healthchecks are added and only if those start to error
 func New(opts ...Opt) *Healthchecks {
-	facade := &Healthchecks{handler: healthcheck.NewHandler()}
+	facade := &Healthchecks{handler: healthcheck.NewHandler(), results: map[string]checkResult{}}
 
 	for _, optionFunc := range opts {
 		optionFunc(&facade.opts)
 	}
 
+	for _, each := range facade.ReadinessChecks {
+		facade.handler.AddReadinessCheck(each.name, facade.recording(each))
+	}
+
 	return facade
 }
 
 type Healthchecks struct {
 	opts
 	handler healthcheck.Handler
+
+	resultsLock sync.Mutex
+	results     map[string]checkResult
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Detail any    `json:"detail,omitempty"`
 }
 
+const (
+	statusUp   = "UP"
+	statusDown = "DOWN"
+)
+
 // StartListening Start the endpoints once we believe that we are broadly healthy
 func (f *Healthchecks) StartListening() {
 	if f.ChiMux != nil {
 		f.ChiMux.Handle("/liveness", http.HandlerFunc(f.handler.LiveEndpoint))
-		f.ChiMux.Handle("/readiness", http.HandlerFunc(f.handler.ReadyEndpoint))
+		f.ChiMux.Handle("/readiness", http.HandlerFunc(f.readyEndpoint))
+	}
+}
+
+var errShuttingDown = errors.New("shutting down")
+
+// ShuttingDown fails readiness from now on, so that traffic stops being routed here while in-flight requests finish
+func (f *Healthchecks) ShuttingDown() {
+	f.handler.AddReadinessCheck("shutdown", f.recording(namedCheck{name: "shutdown", check: func() (any, error) {
+		return nil, errShuttingDown
+	}}))
+}
+
+// Keep the detail behind each check's result, since `healthcheck.Handler` only reports errors
+func (f *Healthchecks) recording(nc namedCheck) healthcheck.Check {
+	return func() error {
+		detail, err := nc.check()
+
+		result := checkResult{Status: statusUp, Detail: detail}
+		if err != nil {
+			result.Status = statusDown
+			result.Error = err.Error()
+		}
+
+		f.resultsLock.Lock()
+		f.results[nc.name] = result
+		f.resultsLock.Unlock()
+
+		return err
 	}
 }
+
+func (f *Healthchecks) readyEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		f.handler.ReadyEndpoint(w, r)
+		return
+	}
+
+	// Let the handler run the checks and decide the status, but report our own detail
+	capture := &statusCapture{header: http.Header{}}
+	f.handler.ReadyEndpoint(capture, r)
+
+	status := statusUp
+	if capture.status != http.StatusOK {
+		status = statusDown
+	}
+
+	f.resultsLock.Lock()
+	body := map[string]any{"status": status, "checks": f.results}
+	bytes, err := json.Marshal(body)
+	f.resultsLock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(capture.status)
+	_, _ = w.Write(bytes)
+}
+
+type statusCapture struct {
+	header http.Header
+	status int
+}
+
+func (c *statusCapture) Header() http.Header {
+	return c.header
+}
+
+func (c *statusCapture) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c *statusCapture) WriteHeader(statusCode int) {
+	c.status = statusCode
+}
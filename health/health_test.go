@@ -0,0 +1,86 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Opt
+		statusCode int
+		jsonOutput string
+	}{
+		{
+			name:       "no checks",
+			statusCode: 200,
+			jsonOutput: `{"checks":{},"status":"UP"}`,
+		},
+		{
+			name: "passing check",
+			opts: []Opt{
+				WithReadinessCheck("backend:git", func() (any, error) {
+					return map[string]any{"version": "abc"}, nil
+				}),
+			},
+			statusCode: 200,
+			jsonOutput: `{"checks":{"backend:git":{"status":"UP","detail":{"version":"abc"}}},"status":"UP"}`,
+		},
+		{
+			name: "failing check",
+			opts: []Opt{
+				WithReadinessCheck("backend:git", func() (any, error) {
+					return map[string]any{"version": "abc"}, errors.New("stale")
+				}),
+				WithReadinessCheck("k8s", func() (any, error) {
+					return nil, nil
+				}),
+			},
+			statusCode: 503,
+			jsonOutput: `{"checks":{"backend:git":{"status":"DOWN","error":"stale","detail":{"version":"abc"}},"k8s":{"status":"UP"}},"status":"DOWN"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := chi.NewRouter()
+			New(append(tt.opts, WithChiMux(router))...).StartListening()
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+
+			assert.Equal(t, tt.statusCode, rr.Code)
+			assert.Equal(t, tt.jsonOutput, strings.TrimSpace(rr.Body.String()))
+
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/liveness", nil))
+			assert.Equal(t, 200, rr.Code)
+		})
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	router := chi.NewRouter()
+	checks := New(WithChiMux(router), WithReadinessCheck("k8s", func() (any, error) {
+		return nil, nil
+	}))
+	checks.StartListening()
+
+	checks.ShuttingDown()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+
+	assert.Equal(t, 503, rr.Code)
+	assert.Equal(t, `{"checks":{"k8s":{"status":"UP"},"shutdown":{"status":"DOWN","error":"shutting down"}},"status":"DOWN"}`, strings.TrimSpace(rr.Body.String()))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/liveness", nil))
+	assert.Equal(t, 200, rr.Code)
+}
@@ -5,13 +5,28 @@ import (
 )
 
 type opts struct {
-	ChiMux *chi.Mux
+	ChiMux          *chi.Mux
+	ReadinessChecks []namedCheck
 }
 
 type Opt func(*opts)
 
+// Check reports detail about an upstream dependency, plus an error if that dependency means we cannot serve requests
+type Check func() (any, error)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
 func WithChiMux(mux *chi.Mux) Opt {
 	return func(o *opts) {
 		o.ChiMux = mux
 	}
 }
+
+func WithReadinessCheck(name string, check Check) Opt {
+	return func(o *opts) {
+		o.ReadinessChecks = append(o.ReadinessChecks, namedCheck{name: name, check: check})
+	}
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/rs/zerolog/log"
+)
+
+const maxPayloadBytes = 5 << 20
+
+// Handler accepts push webhooks, refreshing the affected repository / branch straight away, and responds with the
+// names of the changed configuration files, or `*` if unknown, as per Spring Cloud Config Monitor
+func Handler(cfg config.Monitor, backends backend.Backends) http.HandlerFunc {
+	if cfg.Secret == "" {
+		log.Warn().Msg("Monitor endpoint has no secret, so notifications are not verified")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		p := detectProvider(r.Header)
+
+		if cfg.Secret != "" {
+			if e := p.verify(r.Header, body, cfg.Secret); e != nil {
+				writeError(w, http.StatusUnauthorized, errors.New(p.name+" notification rejected: "+e.Error()))
+				return
+			}
+		}
+
+		n, err := p.parse(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("unparseable "+p.name+" notification: "+err.Error()))
+			return
+		}
+
+		log.Info().Msgf("Received %s notification for %v [%s]", p.name, n.Locations, n.Branch)
+
+		// Carry on even if the webhook sender gives up waiting
+		ctxt := context.WithoutCancel(r.Context())
+
+		var errs []error
+		for _, each := range backends {
+			if refreshable, ok := each.(backend.Refreshable); ok {
+				if _, e := refreshable.Refresh(ctxt, n.Locations, n.Branch); e != nil {
+					errs = append(errs, e)
+				}
+			}
+		}
+
+		if e := errors.Join(errs...); e != nil {
+			writeError(w, http.StatusInternalServerError, e)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(changedNames(n.Files))
+	}
+}
+
+func changedNames(files []string) []string {
+	if len(files) == 0 {
+		return []string{"*"}
+	}
+
+	names := []string{}
+	for _, each := range files {
+		name := path.Base(each)
+		name = strings.TrimSuffix(name, path.Ext(name))
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	info := map[string]any{"message": err.Error()}
+	_ = json.NewEncoder(w).Encode(info)
+
+	log.Error().Err(err).Msg("Monitor error")
+}
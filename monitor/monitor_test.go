@@ -0,0 +1,194 @@
+package monitor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "s3cret"
+
+const gitHubPayload = `{
+  "ref": "refs/heads/main",
+  "repository": {"clone_url": "https://github.com/Org/config.git", "ssh_url": "git@github.com:Org/config.git"},
+  "commits": [{"added": ["accounts-prod.yml"], "modified": ["shared/application.yml"], "removed": []}, {"modified": ["accounts-prod.yml"]}]
+}`
+
+const gitLabPayload = `{
+  "ref": "refs/heads/develop",
+  "project": {"git_http_url": "https://gitlab.example.com/org/config.git", "git_ssh_url": "git@gitlab.example.com:org/config.git"},
+  "commits": [{"added": [], "modified": ["payments.yaml"], "removed": []}]
+}`
+
+const bitbucketCloudPayload = `{
+  "push": {"changes": [{"new": {"type": "branch", "name": "main"}}]},
+  "repository": {"links": {"html": {"href": "https://bitbucket.org/org/config"}}}
+}`
+
+const bitbucketServerPayload = `{
+  "changes": [{"refId": "refs/heads/release"}],
+  "repository": {"links": {"clone": [{"href": "ssh://git@bitbucket.example.com:7999/org/config.git"}]}}
+}`
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		headers    map[string]string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantCall   *refreshCall
+	}{
+		{
+			name:       "github",
+			secret:     secret,
+			headers:    map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(gitHubPayload)},
+			body:       gitHubPayload,
+			wantStatus: http.StatusOK,
+			wantBody:   `["accounts-prod","application"]`,
+			wantCall:   &refreshCall{locations: []string{"https://github.com/Org/config.git", "git@github.com:Org/config.git"}, branch: "main"},
+		},
+		{
+			name:       "github bad signature",
+			secret:     secret,
+			headers:    map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign("other")},
+			body:       gitHubPayload,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"message":"github notification rejected: signature does not match"}`,
+		},
+		{
+			name:       "github unsigned",
+			secret:     secret,
+			headers:    map[string]string{"X-GitHub-Event": "push"},
+			body:       gitHubPayload,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"message":"github notification rejected: missing signature"}`,
+		},
+		{
+			name:       "gitea",
+			secret:     secret,
+			headers:    map[string]string{"X-Gitea-Event": "push", "X-GitHub-Event": "push", "X-Gitea-Signature": sign(gitHubPayload)},
+			body:       gitHubPayload,
+			wantStatus: http.StatusOK,
+			wantBody:   `["accounts-prod","application"]`,
+			wantCall:   &refreshCall{locations: []string{"https://github.com/Org/config.git", "git@github.com:Org/config.git"}, branch: "main"},
+		},
+		{
+			name:       "gitlab",
+			secret:     secret,
+			headers:    map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret},
+			body:       gitLabPayload,
+			wantStatus: http.StatusOK,
+			wantBody:   `["payments"]`,
+			wantCall:   &refreshCall{locations: []string{"https://gitlab.example.com/org/config.git", "git@gitlab.example.com:org/config.git"}, branch: "develop"},
+		},
+		{
+			name:       "gitlab wrong token",
+			secret:     secret,
+			headers:    map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "nope"},
+			body:       gitLabPayload,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"message":"gitlab notification rejected: signature does not match"}`,
+		},
+		{
+			name:       "bitbucket cloud",
+			secret:     secret,
+			headers:    map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": "sha256=" + sign(bitbucketCloudPayload)},
+			body:       bitbucketCloudPayload,
+			wantStatus: http.StatusOK,
+			wantBody:   `["*"]`,
+			wantCall:   &refreshCall{locations: []string{"https://bitbucket.org/org/config"}, branch: "main"},
+		},
+		{
+			name:       "bitbucket server",
+			secret:     secret,
+			headers:    map[string]string{"X-Event-Key": "repo:refs_changed", "X-Hub-Signature": "sha256=" + sign(bitbucketServerPayload)},
+			body:       bitbucketServerPayload,
+			wantStatus: http.StatusOK,
+			wantBody:   `["*"]`,
+			wantCall:   &refreshCall{locations: []string{"ssh://git@bitbucket.example.com:7999/org/config.git"}, branch: "release"},
+		},
+		{
+			name:       "spring form",
+			headers:    map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:       "path=accounts",
+			wantStatus: http.StatusOK,
+			wantBody:   `["accounts"]`,
+			wantCall:   &refreshCall{},
+		},
+		{
+			name:       "spring form needs a signature once there's a secret",
+			secret:     secret,
+			body:       "path=accounts",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"message":"form notification rejected: missing signature"}`,
+		},
+		{
+			name:       "unparseable",
+			headers:    map[string]string{"X-GitHub-Event": "push"},
+			body:       "{",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"unparseable github notification: unexpected end of JSON input"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshable := &fakeBackend{}
+
+			req := httptest.NewRequest(http.MethodPost, "/monitor", strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			Handler(config.Monitor{Enabled: true, Secret: tt.secret}, backend.Backends{refreshable}).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rr.Body.String()))
+			assert.Equal(t, tt.wantCall, refreshable.call)
+		})
+	}
+}
+
+func TestHandlerRefreshFailure(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/monitor", strings.NewReader("path=accounts"))
+	rr := httptest.NewRecorder()
+
+	Handler(config.Monitor{}, backend.Backends{&fakeBackend{err: errors.New("unreachable")}}).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, `{"message":"unreachable"}`, strings.TrimSpace(rr.Body.String()))
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type refreshCall struct {
+	locations []string
+	branch    string
+}
+
+type fakeBackend struct {
+	backend.Backend
+	call *refreshCall
+	err  error
+}
+
+func (f *fakeBackend) Refresh(_ context.Context, locations []string, branch string) ([]string, error) {
+	f.call = &refreshCall{locations: locations, branch: branch}
+	return nil, f.err
+}
@@ -0,0 +1,252 @@
+package monitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errBadSignature     = errors.New("signature does not match")
+)
+
+// What a push notification tells us
+type notification struct {
+	Locations []string // any of the repository's URLs
+	Branch    string   // blank if unknown, or several
+	Files     []string // blank if unknown
+}
+
+type provider struct {
+	name   string
+	verify func(header http.Header, body []byte, secret string) error
+	parse  func(body []byte) (notification, error)
+}
+
+var (
+	github = provider{
+		name: "github",
+		verify: func(header http.Header, body []byte, secret string) error {
+			return verifyHmac(header.Get("X-Hub-Signature-256"), "sha256=", body, secret)
+		},
+		parse: parseGitHubStyle,
+	}
+	gitea = provider{
+		name: "gitea",
+		verify: func(header http.Header, body []byte, secret string) error {
+			return verifyHmac(header.Get("X-Gitea-Signature"), "", body, secret)
+		},
+		parse: parseGitHubStyle,
+	}
+	gitlab = provider{
+		name: "gitlab",
+		verify: func(header http.Header, _ []byte, secret string) error {
+			token := header.Get("X-Gitlab-Token")
+			if token == "" {
+				return errMissingSignature
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+				return errBadSignature
+			}
+			return nil
+		},
+		parse: parseGitLab,
+	}
+	bitbucket = provider{
+		name: "bitbucket",
+		verify: func(header http.Header, body []byte, secret string) error {
+			return verifyHmac(header.Get("X-Hub-Signature"), "sha256=", body, secret)
+		},
+		parse: parseBitbucket,
+	}
+	// As per Spring Cloud Config Monitor, e.g. `curl -X POST /monitor -d path=accounts`
+	form = provider{
+		name: "form",
+		verify: func(_ http.Header, _ []byte, _ string) error {
+			return errMissingSignature
+		},
+		parse: parseForm,
+	}
+)
+
+// Gitea also sends GitHub's headers, so has to be checked first
+func detectProvider(header http.Header) provider {
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		return gitea
+	case header.Get("X-GitHub-Event") != "":
+		return github
+	case header.Get("X-Gitlab-Event") != "":
+		return gitlab
+	case header.Get("X-Event-Key") != "":
+		return bitbucket
+	default:
+		return form
+	}
+}
+
+func verifyHmac(signature string, prefix string, body []byte, secret string) error {
+	if signature == "" {
+		return errMissingSignature
+	}
+
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return errBadSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(sent, mac.Sum(nil)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+type commitFiles struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+func (c commitFiles) all() []string {
+	return append(append(append([]string{}, c.Added...), c.Modified...), c.Removed...)
+}
+
+type gitHubPush struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneUrl string `json:"clone_url"`
+		SshUrl   string `json:"ssh_url"`
+		HtmlUrl  string `json:"html_url"`
+	} `json:"repository"`
+	Commits []commitFiles `json:"commits"`
+}
+
+func parseGitHubStyle(body []byte) (notification, error) {
+	var push gitHubPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return notification{}, err
+	}
+
+	n := notification{
+		Locations: nonBlank(push.Repository.CloneUrl, push.Repository.SshUrl, push.Repository.HtmlUrl),
+		Branch:    branchName(push.Ref),
+	}
+	for _, each := range push.Commits {
+		n.Files = append(n.Files, each.all()...)
+	}
+	return n, nil
+}
+
+type gitLabPush struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		GitHttpUrl string `json:"git_http_url"`
+		GitSshUrl  string `json:"git_ssh_url"`
+		WebUrl     string `json:"web_url"`
+	} `json:"project"`
+	Commits []commitFiles `json:"commits"`
+}
+
+func parseGitLab(body []byte) (notification, error) {
+	var push gitLabPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return notification{}, err
+	}
+
+	n := notification{
+		Locations: nonBlank(push.Project.GitHttpUrl, push.Project.GitSshUrl, push.Project.WebUrl),
+		Branch:    branchName(push.Ref),
+	}
+	for _, each := range push.Commits {
+		n.Files = append(n.Files, each.all()...)
+	}
+	return n, nil
+}
+
+// Covers Bitbucket Cloud (`repo:push`) and Bitbucket Server / Data Center (`repo:refs_changed`). Neither lists files.
+type bitbucketPush struct {
+	Push struct {
+		Changes []struct {
+			New struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+	Changes []struct {
+		RefId string `json:"refId"`
+	} `json:"changes"`
+	Repository struct {
+		Links struct {
+			Html struct {
+				Href string `json:"href"`
+			} `json:"html"`
+			Clone []struct {
+				Href string `json:"href"`
+			} `json:"clone"`
+		} `json:"links"`
+	} `json:"repository"`
+}
+
+func parseBitbucket(body []byte) (notification, error) {
+	var push bitbucketPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return notification{}, err
+	}
+
+	n := notification{Locations: nonBlank(push.Repository.Links.Html.Href)}
+	for _, each := range push.Repository.Links.Clone {
+		n.Locations = append(n.Locations, nonBlank(each.Href)...)
+	}
+
+	var branches []string
+	for _, each := range push.Push.Changes {
+		if each.New.Type == "branch" {
+			branches = append(branches, each.New.Name)
+		}
+	}
+	for _, each := range push.Changes {
+		if branch := branchName(each.RefId); branch != "" {
+			branches = append(branches, branch)
+		}
+	}
+	if len(branches) == 1 {
+		n.Branch = branches[0]
+	}
+
+	return n, nil
+}
+
+func parseForm(body []byte) (notification, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return notification{}, err
+	}
+	return notification{Files: values["path"]}, nil
+}
+
+func branchName(ref string) string {
+	if branch, found := strings.CutPrefix(ref, "refs/heads/"); found {
+		return branch
+	}
+	return ""
+}
+
+func nonBlank(values ...string) []string {
+	var result []string
+	for _, each := range values {
+		if each != "" {
+			result = append(result, each)
+		}
+	}
+	return result
+}
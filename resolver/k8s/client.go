@@ -2,12 +2,14 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/GlintPay/gccs/config"
 	"github.com/rs/zerolog/log"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -21,6 +23,8 @@ type Client struct {
 	cache     *resourceCache
 }
 
+const pingTimeout = 5 * time.Second
+
 type resourceCache struct {
 	mu      sync.RWMutex
 	entries map[string]cacheEntry
@@ -73,6 +77,39 @@ func NewClient(cfg config.K8sConfig) (*Client, error) {
 	return client, nil
 }
 
+// Ping checks that the API server is reachable with the credentials in use
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := c.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("K8s API unreachable: %w", err)
+	}
+	return nil
+}
+
+// ReviewToken confirms a token with the TokenReview API, returning the user it authenticates. Requires the
+// `system:auth-delegator` ClusterRole, or another granting `create` on `tokenreviews`.
+func (c *Client) ReviewToken(ctx context.Context, token string, audiences []string) (string, error) {
+	review, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("token review failed: %w", err)
+	}
+
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return "", fmt.Errorf("token not authenticated: %s", review.Status.Error)
+		}
+		return "", errors.New("token not authenticated")
+	}
+	return review.Status.User.Username, nil
+}
+
 func (c *Client) GetSecretValue(ctx context.Context, namespace, name, key string) (string, bool, error) {
 	cacheKey := fmt.Sprintf("secret:%s/%s/%s", namespace, name, key)
 
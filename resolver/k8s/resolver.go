@@ -27,6 +27,11 @@ func NewResolver(client *Client, cfg config.K8sConfig) *Resolver {
 	}
 }
 
+// Ping checks that placeholders can currently be resolved against the K8s API
+func (r *Resolver) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx)
+}
+
 // IsK8sPlaceholder checks if the placeholder starts with a k8s prefix (does not require an instance)
 func IsK8sPlaceholder(placeholder string) bool {
 	return strings.HasPrefix(placeholder, PrefixK8sSecret) ||
@@ -18,6 +18,11 @@ func SplitProfileNames(csv string) []string {
 	return splitNonEmpty(csv)
 }
 
+// SplitLabels as both authorization and the backends read them, so each sees the same chain, e.g. `feature-x, main`
+func SplitLabels(csv string) []string {
+	return splitNonEmpty(csv)
+}
+
 func splitNonEmpty(csv string) []string {
 	array := strings.Split(csv, ",")
 	adjusted := make([]string, 0)
@@ -33,6 +33,20 @@ func TestSplitProfileNames(t *testing.T) {
 	}
 }
 
+func TestSplitLabels(t *testing.T) {
+	tests := []csvExpectation{
+		{csv: "feature-x,main", want: []string{"feature-x", "main"}},
+		{csv: " feature-x, ,main ", want: []string{"feature-x", "main"}},
+		{csv: " prod", want: []string{"prod"}},
+		{csv: "", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.csv, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitLabels(tt.csv))
+		})
+	}
+}
+
 type csvExpectation struct {
 	csv  string
 	want []string
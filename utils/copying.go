@@ -0,0 +1,29 @@
+package utils
+
+// DeepCopy copies nested maps and lists, as produced by parsing YAML, so the copy can be modified freely
+func DeepCopy(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		result[k] = deepCopyValue(v)
+	}
+	return result
+}
+
+func deepCopyValue(v any) any {
+	switch typed := v.(type) {
+	case map[string]any:
+		return DeepCopy(typed)
+	case []any:
+		result := make([]any, len(typed))
+		for i, each := range typed {
+			result[i] = deepCopyValue(each)
+		}
+		return result
+	default:
+		return v
+	}
+}
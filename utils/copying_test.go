@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepCopy(t *testing.T) {
+	original := map[string]any{
+		"a": "b",
+		"c": map[string]any{"d": 1.0},
+		"e": []any{"f", map[string]any{"g": true}},
+	}
+
+	copied := DeepCopy(original)
+	assert.Equal(t, original, copied)
+
+	copied["c"].(map[string]any)["d"] = 2.0
+	copied["e"].([]any)[0] = "x"
+	copied["e"].([]any)[1].(map[string]any)["g"] = false
+
+	assert.Equal(t, map[string]any{
+		"a": "b",
+		"c": map[string]any{"d": 1.0},
+		"e": []any{"f", map[string]any{"g": true}},
+	}, original)
+
+	assert.Nil(t, DeepCopy(nil))
+}
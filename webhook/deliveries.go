@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+	"github.com/google/uuid"
+)
+
+func (d *Dispatcher) record(event Event, endpoint config.WebhookEndpoint) *Delivery {
+	delivery := &Delivery{
+		Id:       uuid.NewString(),
+		EventId:  event.Id,
+		Endpoint: endpoint.Name,
+		Status:   statusPending,
+		Created:  time.Now().UTC(),
+	}
+	delivery.Updated = delivery.Created
+
+	d.logLock.Lock()
+	defer d.logLock.Unlock()
+
+	d.deliveries = append(d.deliveries, delivery)
+	if excess := len(d.deliveries) - d.config.LogSize; excess > 0 {
+		d.deliveries = d.deliveries[excess:]
+	}
+	return delivery
+}
+
+func (d *Dispatcher) update(delivery *Delivery, attempt int, code int, err error) {
+	d.logLock.Lock()
+	defer d.logLock.Unlock()
+
+	delivery.Attempts = attempt
+	delivery.ResponseCode = code
+	delivery.Updated = time.Now().UTC()
+
+	switch {
+	case err == nil:
+		delivery.Status = statusDelivered
+		delivery.LastError = ""
+	case attempt >= d.config.MaxAttempts:
+		delivery.Status = statusFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+	}
+}
+
+// abandon records a delivery as failed, however many attempts it had left, e.g. on Close
+func (d *Dispatcher) abandon(delivery *Delivery, attempts int, err error) {
+	d.logLock.Lock()
+	defer d.logLock.Unlock()
+
+	delivery.Attempts = attempts
+	delivery.Status = statusFailed
+	delivery.LastError = fmt.Sprintf("abandoned: %v", err)
+	delivery.Updated = time.Now().UTC()
+}
+
+// Deliveries returns the most recent first
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.logLock.RLock()
+	defer d.logLock.RUnlock()
+
+	result := make([]Delivery, 0, len(d.deliveries))
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		result = append(result, *d.deliveries[i])
+	}
+	return result
+}
+
+// DeliveriesHandler serves the delivery log
+func (d *Dispatcher) DeliveriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d.Deliveries())
+	}
+}
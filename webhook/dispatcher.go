@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+func New(cfg config.Webhooks) *Dispatcher {
+	cfg = cfg.Validate()
+	ctxt, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		config: cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.TimeoutMillis) * time.Millisecond},
+		ctxt:   ctxt,
+		cancel: cancel,
+	}
+}
+
+// Publish sends the change to each interested endpoint in the background, retrying as necessary
+func (d *Dispatcher) Publish(change backend.Change) {
+	event := Event{
+		Id:              uuid.NewString(),
+		Type:            eventType,
+		Timestamp:       time.Now().UTC(),
+		Backend:         change.Backend,
+		Location:        change.Location,
+		Label:           change.Label,
+		PreviousVersion: change.PreviousVersion,
+		Version:         change.Version,
+		Files:           change.Files,
+		Affected:        affectedTargets(change.Files),
+	}
+
+	if d.ctxt.Err() != nil {
+		log.Warn().Msgf("Webhooks closed, not publishing %s [%s] %s", event.Location, event.Label, event.Version)
+		return
+	}
+
+	if len(event.Affected) == 0 {
+		log.Debug().Msgf("No configuration affected by %s [%s] %s", event.Location, event.Label, event.Version)
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Msg("Unmarshallable webhook event")
+		return
+	}
+
+	for _, endpoint := range d.config.Endpoints {
+		if !interestedIn(endpoint, event.Affected) {
+			continue
+		}
+
+		delivery := d.record(event, endpoint)
+
+		d.inFlight.Add(1)
+		go func() {
+			defer d.inFlight.Done()
+			d.deliver(endpoint, delivery, body)
+		}()
+	}
+}
+
+// Wait for any deliveries still being attempted
+func (d *Dispatcher) Wait() {
+	d.inFlight.Wait()
+}
+
+// Close abandons any deliveries still being attempted, including any requests under way, and waits for them to stop
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.inFlight.Wait()
+}
+
+func (d *Dispatcher) deliver(endpoint config.WebhookEndpoint, delivery *Delivery, body []byte) {
+	backoff := time.Duration(d.config.InitialBackoffMillis) * time.Millisecond
+	maxBackoff := time.Duration(d.config.MaxBackoffMillis) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		code, err := d.post(endpoint, delivery, body)
+		if err != nil && d.ctxt.Err() != nil {
+			d.abandon(delivery, attempt, err)
+			log.Warn().Err(err).Msgf("Webhook [%s] abandoned after %d attempts", endpoint.Name, attempt)
+			return
+		}
+		d.update(delivery, attempt, code, err)
+
+		if err == nil {
+			log.Info().Msgf("Webhook [%s] delivered event %s", endpoint.Name, delivery.EventId)
+			return
+		}
+		if attempt >= d.config.MaxAttempts {
+			log.Error().Err(err).Msgf("Webhook [%s] failed after %d attempts", endpoint.Name, attempt)
+			return
+		}
+
+		log.Warn().Err(err).Msgf("Webhook [%s] attempt %d failed, retrying in %v", endpoint.Name, attempt, backoff)
+		select {
+		case <-d.ctxt.Done():
+			d.abandon(delivery, attempt, d.ctxt.Err())
+			log.Warn().Msgf("Webhook [%s] abandoned after %d attempts", endpoint.Name, attempt)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (d *Dispatcher) post(endpoint config.WebhookEndpoint, delivery *Delivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctxt, http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gccs-Event", eventType)
+	req.Header.Set("X-Gccs-Delivery", delivery.Id)
+	if endpoint.Secret != "" {
+		req.Header.Set("X-Gccs-Signature", "sha256="+sign(body, endpoint.Secret))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func interestedIn(endpoint config.WebhookEndpoint, affected []Target) bool {
+	if len(endpoint.Applications) == 0 {
+		return true
+	}
+
+	for _, target := range affected {
+		if target.Application == "*" {
+			return true
+		}
+		for _, pattern := range endpoint.Applications {
+			if matched, _ := path.Match(pattern, target.Application); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// From file names alone, `accounts-production.yml` could be application `accounts-production` with any profile, or
+// `accounts` with profile `production`, so both are included
+func affectedTargets(files []string) []Target {
+	var targets []Target
+	add := func(t Target) {
+		if !slices.Contains(targets, t) {
+			targets = append(targets, t)
+		}
+	}
+
+	for _, each := range files {
+		name := path.Base(each)
+		ext := path.Ext(name)
+		if ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		name = strings.TrimSuffix(name, ext)
+
+		if name == "application" {
+			add(Target{Application: "*", Profile: "*"})
+			continue
+		}
+		if profile, found := strings.CutPrefix(name, "application-"); found {
+			add(Target{Application: "*", Profile: profile})
+			continue
+		}
+
+		add(Target{Application: name, Profile: "*"})
+		for i := range name {
+			if name[i] == '-' && i > 0 && i < len(name)-1 {
+				add(Target{Application: name[:i], Profile: name[i+1:]})
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlintPay/gccs/backend"
+	"github.com/GlintPay/gccs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffectedTargets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []Target
+	}{
+		{name: "not configuration", files: []string{"README.md"}, want: nil},
+		{name: "application", files: []string{"application.yml"}, want: []Target{{"*", "*"}}},
+		{name: "application profile", files: []string{"shared/application-prod.yaml"}, want: []Target{{"*", "prod"}}},
+		{name: "application name", files: []string{"accounts.yaml"}, want: []Target{{"accounts", "*"}}},
+		{
+			name:  "ambiguous",
+			files: []string{"payments-api-prod.yaml", "payments-api-prod.yaml"},
+			want:  []Target{{"payments-api-prod", "*"}, {"payments", "api-prod"}, {"payments-api", "prod"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, affectedTargets(tt.files))
+		})
+	}
+}
+
+func TestInterestedIn(t *testing.T) {
+	assert.True(t, interestedIn(config.WebhookEndpoint{}, []Target{{"accounts", "*"}}))
+	assert.True(t, interestedIn(config.WebhookEndpoint{Applications: []string{"pay*"}}, []Target{{"accounts", "*"}, {"payments", "*"}}))
+	assert.True(t, interestedIn(config.WebhookEndpoint{Applications: []string{"pay*"}}, []Target{{"*", "prod"}}))
+	assert.False(t, interestedIn(config.WebhookEndpoint{Applications: []string{"pay*"}}, []Target{{"accounts", "*"}}))
+}
+
+func TestPublish(t *testing.T) {
+	var calls atomic.Int32
+	var received Event
+	var signature string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Gccs-Signature")
+		assert.Equal(t, "sha256="+sign(body, "s3cret"), signature)
+		assert.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	d := New(config.Webhooks{
+		Endpoints: []config.WebhookEndpoint{
+			{Name: "clients", Url: server.URL, Secret: "s3cret"},
+			{Name: "broken", Url: failing.URL},
+			{Name: "uninterested", Url: failing.URL, Applications: []string{"payments"}},
+		},
+		MaxAttempts:          3,
+		InitialBackoffMillis: 1,
+	})
+
+	d.Publish(backend.Change{
+		Backend:         "git",
+		Location:        "git@github.com:Org/config.git",
+		Label:           "main",
+		PreviousVersion: "abc",
+		Version:         "def",
+		Files:           []string{"accounts-production.yml", "README.md"},
+	})
+	d.Wait()
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, eventType, received.Type)
+	assert.Equal(t, "def", received.Version)
+	assert.Equal(t, "main", received.Label)
+	assert.Equal(t, []string{"accounts-production.yml", "README.md"}, received.Files)
+	assert.Equal(t, []Target{{"accounts-production", "*"}, {"accounts", "production"}}, received.Affected)
+
+	deliveries := d.Deliveries()
+	require.Len(t, deliveries, 2)
+
+	byEndpoint := map[string]Delivery{}
+	for _, each := range deliveries {
+		byEndpoint[each.Endpoint] = each
+		assert.Equal(t, received.Id, each.EventId)
+	}
+
+	assert.Equal(t, statusDelivered, byEndpoint["clients"].Status)
+	assert.Equal(t, 2, byEndpoint["clients"].Attempts)
+	assert.Equal(t, http.StatusOK, byEndpoint["clients"].ResponseCode)
+	assert.Empty(t, byEndpoint["clients"].LastError)
+
+	assert.Equal(t, statusFailed, byEndpoint["broken"].Status)
+	assert.Equal(t, 3, byEndpoint["broken"].Attempts)
+	assert.Equal(t, "unexpected response status 500", byEndpoint["broken"].LastError)
+}
+
+func TestCloseAbandonsDeliveries(t *testing.T) {
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	d := New(config.Webhooks{
+		Endpoints: []config.WebhookEndpoint{
+			{Name: "hanging", Url: hanging.URL},
+			{Name: "backing off", Url: failing.URL},
+		},
+		MaxAttempts:          10,
+		InitialBackoffMillis: 60_000,
+		TimeoutMillis:        60_000,
+	})
+
+	d.Publish(backend.Change{Version: "def", Files: []string{"accounts.yml"}})
+
+	// Until the first attempt at each is under way
+	require.Eventually(t, func() bool {
+		for _, each := range d.Deliveries() {
+			if each.Endpoint == "backing off" && each.Attempts == 1 {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	started := time.Now()
+	d.Close()
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	for _, each := range d.Deliveries() {
+		assert.Equal(t, statusFailed, each.Status, each.Endpoint)
+		assert.Equal(t, 1, each.Attempts, each.Endpoint)
+		assert.Contains(t, each.LastError, "abandoned: ", each.Endpoint)
+	}
+
+	// Nothing more once closed
+	d.Publish(backend.Change{Version: "ghi", Files: []string{"accounts.yml"}})
+	assert.Len(t, d.Deliveries(), 2)
+}
+
+func TestDeliveryLog(t *testing.T) {
+	d := New(config.Webhooks{LogSize: 2})
+
+	for _, id := range []string{"1", "2", "3"} {
+		d.record(Event{Id: id}, config.WebhookEndpoint{Name: "e"})
+	}
+
+	rr := httptest.NewRecorder()
+	d.DeliveriesHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/webhooks/deliveries", nil))
+
+	var deliveries []Delivery
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "3", deliveries[0].EventId)
+	assert.Equal(t, "2", deliveries[1].EventId)
+	assert.Equal(t, statusPending, deliveries[0].Status)
+}
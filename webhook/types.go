@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GlintPay/gccs/config"
+)
+
+// Event is what each endpoint is sent when a backend picks up a change
+type Event struct {
+	Id              string    `json:"id"`
+	Type            string    `json:"type"`
+	Timestamp       time.Time `json:"timestamp"`
+	Backend         string    `json:"backend"`
+	Location        string    `json:"location"`
+	Label           string    `json:"label"`
+	PreviousVersion string    `json:"previousVersion"`
+	Version         string    `json:"version"`
+	Files           []string  `json:"files"`
+	Affected        []Target  `json:"affected"`
+}
+
+// Target is an application / profile pair whose configuration may have changed, where `*` means any
+type Target struct {
+	Application string `json:"application"`
+	Profile     string `json:"profile"`
+}
+
+type Delivery struct {
+	Id           string    `json:"id"`
+	EventId      string    `json:"eventId"`
+	Endpoint     string    `json:"endpoint"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode int       `json:"responseCode,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+}
+
+const (
+	eventType = "config.changed"
+
+	statusPending   = "pending"
+	statusDelivered = "delivered"
+	statusFailed    = "failed"
+)
+
+type Dispatcher struct {
+	config config.Webhooks
+	client *http.Client
+
+	logLock    sync.RWMutex
+	deliveries []*Delivery // oldest first, at most `LogSize`
+
+	ctxt     context.Context // cancelled on Close
+	cancel   context.CancelFunc
+	inFlight sync.WaitGroup
+}